@@ -1,17 +1,43 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/krisk248/seyal/internal/app"
+	"github.com/krisk248/seyal/internal/cli"
+	"github.com/krisk248/seyal/internal/storage"
 )
 
 func main() {
+	// Subcommands run headless and never start the TUI
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
 	// Create new model
 	m := app.NewModel()
 
+	// Hold the single-writer lock for the whole session. A second instance
+	// can still browse the data but never saves over the first one.
+	ownsData := false
+	if store, err := storage.NewStorage(); err == nil {
+		lock, err := store.Lock()
+		var locked *storage.LockedError
+		switch {
+		case errors.As(err, &locked):
+			m.SetReadOnly(locked.Error())
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error locking data file: %v\n", err)
+			os.Exit(1)
+		default:
+			defer lock.Unlock()
+			ownsData = true
+		}
+	}
+
 	// Create Bubbletea program
 	p := tea.NewProgram(
 		m,
@@ -19,6 +45,13 @@ func main() {
 		tea.WithMouseCellMotion(),
 	)
 
+	// Accept commands from the CLI and other tools while the TUI is open
+	if ownsData {
+		if ln, err := app.ServeSocket(p); err == nil {
+			defer ln.Close()
+		}
+	}
+
 	// Run the program
 	if _, err := p.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error running tasklog: %v\n", err)
@@ -2,6 +2,8 @@ package app
 
 import (
 	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/ipc"
+	"github.com/krisk248/seyal/internal/storage"
 )
 
 // Message types for Bubbletea
@@ -25,6 +27,16 @@ const (
 	ModeFilter
 )
 
+// InputKind is what the text input of ModeInput is for
+type InputKind int
+
+const (
+	InputTitle     InputKind = iota // Title of a new task or of EditingTask
+	InputRepeat                     // Repeat rule of EditingTask
+	InputDue                        // Due date of EditingTask
+	InputDueFilter                  // Days ahead the due filter looks
+)
+
 // Dialog represents which dialog is open
 type Dialog int
 
@@ -36,6 +48,11 @@ const (
 	DialogClearTimeline
 	DialogConfirmExit
 	DialogTaskDetails
+	DialogBackups
+	DialogImport
+	DialogStandup
+	DialogTags
+	DialogEditScope
 )
 
 // Messages
@@ -52,12 +69,29 @@ type DateSelectedMsg struct {
 
 // TaskAddedMsg is sent when a new task is added
 type TaskAddedMsg struct {
-	Task *domain.Task
+	Task     *domain.Task
+	ParentID string       // Optional parent to add the task under
+	Repeat   *domain.Rule // Optional rule the task repeats by
 }
 
-// TaskUpdatedMsg is sent when a task is updated
+// TaskUpdatedMsg is sent when a task's title is edited
 type TaskUpdatedMsg struct {
+	Task   *domain.Task
+	Title  string
+	Tags   []string
+	Future bool // Also edit the later occurrences of the task's series
+}
+
+// TaskDueChangedMsg is sent when a task's due date is set or cleared
+type TaskDueChangedMsg struct {
+	Task    *domain.Task
+	DueDate string // YYYY-MM-DD, empty for none
+}
+
+// TaskRepeatMsg is sent when a task is made to repeat, or stops repeating
+type TaskRepeatMsg struct {
 	Task *domain.Task
+	Rule *domain.Rule // Nil stops the series after the task
 }
 
 // TaskDeletedMsg is sent when a task is deleted
@@ -83,6 +117,23 @@ type TaskPushedMsg struct {
 	Task *domain.Task
 }
 
+// TaskStartedMsg is sent when a task's timer is started
+type TaskStartedMsg struct {
+	Task *domain.Task
+}
+
+// TaskStoppedMsg is sent when a task's timer is stopped
+type TaskStoppedMsg struct {
+	Task *domain.Task
+}
+
+// RemoteCommandMsg is sent when a command arrives on the control socket.
+// The model answers on Reply and then applies the command.
+type RemoteCommandMsg struct {
+	Request ipc.Request
+	Reply   chan<- ipc.Response
+}
+
 // TimelineEventMsg is sent when a timeline event occurs
 type TimelineEventMsg struct {
 	Event *domain.TimelineEvent
@@ -116,12 +167,53 @@ const (
 	FilterNone FilterType = iota
 	FilterState
 	FilterPriority
+	FilterTag
+	FilterDue // Open tasks of every day due within FilterValue days
 )
 
+// String returns the filter name shown in the filter indicator
+func (f FilterType) String() string {
+	switch f {
+	case FilterState:
+		return "state"
+	case FilterPriority:
+		return "priority"
+	case FilterTag:
+		return "tag"
+	case FilterDue:
+		return "due"
+	default:
+		return "none"
+	}
+}
+
 // ExportMsg is sent when exporting
 type ExportMsg struct {
-	Format ExportFormat
-	Scope  ExportScope
+	Format   ExportFormat
+	Scope    ExportScope
+	Dates    domain.DateRange
+	Template *storage.ExportTemplate // For ExportUserTemplate
+	Content  storage.ExportContent
+}
+
+// ExportTemplatesLoadedMsg is sent when the export dialog has listed the
+// user's export templates
+type ExportTemplatesLoadedMsg struct {
+	Templates []storage.ExportTemplate
+	Dir       string
+	Error     error
+}
+
+// StandupDoneMsg is sent after the standup was copied or written to a file
+type StandupDoneMsg struct {
+	Path  string // Empty when copied to the clipboard
+	Error error
+}
+
+// ExportedMsg is sent after an export was written
+type ExportedMsg struct {
+	Path  string
+	Error error
 }
 
 // ExportFormat represents export format
@@ -131,8 +223,47 @@ const (
 	ExportMarkdown ExportFormat = iota
 	ExportJSON
 	ExportPlainText
+	ExportICalendar
+	ExportTodoTxt
+	ExportTaskwarrior
+	ExportCSV
+	ExportHTML
+	ExportOrg
+	ExportUserTemplate
 )
 
+// hasTimeline reports whether the format can carry the timeline, and so
+// whether the export dialog offers the content choice
+func (f ExportFormat) hasTimeline() bool {
+	return f == ExportMarkdown || f == ExportJSON || f == ExportPlainText
+}
+
+// storageFormat maps the dialog's format to the storage format
+func (f ExportFormat) storageFormat() storage.ExportFormat {
+	switch f {
+	case ExportJSON:
+		return storage.FormatJSON
+	case ExportPlainText:
+		return storage.FormatPlainText
+	case ExportICalendar:
+		return storage.FormatICalendar
+	case ExportTodoTxt:
+		return storage.FormatTodoTxt
+	case ExportTaskwarrior:
+		return storage.FormatTaskwarrior
+	case ExportCSV:
+		return storage.FormatCSV
+	case ExportHTML:
+		return storage.FormatHTML
+	case ExportOrg:
+		return storage.FormatOrg
+	case ExportUserTemplate:
+		return storage.FormatTemplate
+	default:
+		return storage.FormatMarkdown
+	}
+}
+
 // ExportScope represents export scope
 type ExportScope int
 
@@ -140,6 +271,8 @@ const (
 	ExportCurrentDay ExportScope = iota
 	ExportCurrentMonth
 	ExportAll
+	ExportCurrentWeek
+	ExportRange
 )
 
 // UndoMsg triggers an undo action
@@ -154,11 +287,64 @@ type SavedMsg struct {
 	Error   error
 }
 
+// VaultReadMsg carries the checkboxes changed in the vault's daily notes
+type VaultReadMsg struct {
+	Report *storage.VaultReport
+	Error  error
+}
+
+// VaultWrittenMsg is sent after the daily notes were brought up to date
+type VaultWrittenMsg struct {
+	Conflicts []storage.VaultConflict
+	Error     error
+}
+
 // LoadedMsg is sent when data is loaded
 type LoadedMsg struct {
-	Tasks    domain.TaskTree
-	Timeline domain.Timeline
-	Theme    string
+	Tasks       domain.TaskTree
+	Timeline    domain.Timeline
+	Recurrences domain.Recurrences
+	Theme       string
+	Settings    storage.Settings
+	ReadOnly    string // Non-empty when the data must not be saved
+
+	// Set when the data file could not be read
+	Error       error
+	Quarantined string // Where the unreadable file was moved to
+	Backup      string // Newest backup that could replace it
+}
+
+// BackupsLoadedMsg is sent when the backups dialog has read the backups
+type BackupsLoadedMsg struct {
+	Backups []BackupEntry
+	Error   error
+}
+
+// BackupLoadedMsg carries the data of a backup chosen for restoring
+type BackupLoadedMsg struct {
+	Path        string
+	Tasks       domain.TaskTree
+	Timeline    domain.Timeline
+	Recurrences domain.Recurrences
+	Error       error
+}
+
+// ImportLoadedMsg carries a file read by the import dialog
+type ImportLoadedMsg struct {
+	Path  string
+	Data  *storage.ImportData
+	Error error
+}
+
+// ImportMsg merges imported tasks into the open data
+type ImportMsg struct {
+	Data *storage.ImportData
+}
+
+// BackupRestoredMsg is sent after a backup replaced the data file
+type BackupRestoredMsg struct {
+	Path  string
+	Error error
 }
 
 // ErrorMsg represents an error
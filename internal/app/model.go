@@ -1,6 +1,16 @@
 package app
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/krisk248/seyal/internal/domain"
@@ -25,6 +35,8 @@ type Model struct {
 	// Data
 	Tasks        domain.TaskTree
 	Timeline     domain.Timeline
+	Recurrences  domain.Recurrences
+	Upcoming     domain.TaskTree // Occurrences past the lookahead on the days shown, not made yet
 	SelectedDate domain.CalendarDate
 
 	// Task pane state
@@ -32,6 +44,9 @@ type Model struct {
 	FlattenedTasks    []domain.FlattenedTask
 	TaskScrollOffset  int
 	EditingTask       *domain.Task
+	Input             InputKind       // What the text input is for
+	InputError        error           // Why the typed value was not accepted
+	PendingUpdate     *TaskUpdatedMsg // Edit of an occurrence waiting for its scope
 
 	// Timeline pane state
 	TimelineScrollOffset int
@@ -64,14 +79,72 @@ type Model struct {
 	MaxUndo   int
 
 	// Storage
-	DataPath string
-	IsDirty  bool
+	DataPath   string
+	IsDirty    bool
+	DataLoaded bool   // False until the first LoadedMsg arrives
+	ReadOnly   string // Non-empty when another process owns the data file
+
+	// Settings as loaded, written back on save
+	Settings storage.Settings
+
+	// Why the vault's daily notes are out of sync, shown on the hints line
+	VaultNotice string
+
+	// Export dialog
+	ExportFormat      ExportFormat
+	ExportScope       ExportScope
+	ExportContent     storage.ExportContent
+	ExportRangeInput  textinput.Model
+	ExportResult      string // Path written by the last export
+	ExportError       error
+	ExportTemplates   []storage.ExportTemplate
+	ExportTemplateDir string
+	SelectedTemplate  int
+
+	// Import dialog
+	ImportPathInput textinput.Model
+	ImportData      *storage.ImportData
+	ImportPreview   storage.ImportReport // What merging ImportData would do
+	ImportError     error
+
+	// Standup dialog
+	StandupDate     domain.CalendarDate
+	StandupMarkdown bool   // Markdown rather than plain text
+	StandupResult   string // What the last copy or export did
+	StandupError    error
+
+	// Tags dialog
+	TagCounts   []domain.TagCount
+	SelectedTag int
+
+	// Backups dialog
+	Backups        []BackupEntry
+	BackupsError   error
+	SelectedBackup int
+
+	// Recovery state, set when the data file failed to load
+	LoadError      error
+	QuarantinePath string
+	LatestBackup   string
 }
 
 // UndoState stores state for undo
 type UndoState struct {
-	Tasks    domain.TaskTree
-	Timeline domain.Timeline
+	Tasks       domain.TaskTree
+	Timeline    domain.Timeline
+	Recurrences domain.Recurrences
+}
+
+// BackupEntry describes a backup file in the backups dialog
+type BackupEntry struct {
+	Path      string
+	Time      time.Time
+	Tasks     int
+	Events    int
+	FirstDate string
+	LastDate  string
+	Diff      domain.TreeDiff // What restoring it would change
+	Error     error
 }
 
 // NewModel creates a new application model
@@ -81,6 +154,16 @@ func NewModel() Model {
 	ti.CharLimit = 256
 	ti.Width = 50
 
+	ri := textinput.New()
+	ri.Placeholder = "2006-01-02..2006-01-31"
+	ri.CharLimit = 22
+	ri.Width = 24
+
+	pi := textinput.New()
+	pi.Placeholder = "~/Documents/tasks.md"
+	pi.CharLimit = 1024
+	pi.Width = 50
+
 	currentTheme := theme.GetTheme("ultraviolet")
 
 	return Model{
@@ -105,7 +188,9 @@ func NewModel() Model {
 		TaskScrollOffset:  0,
 
 		// Input
-		TextInput: ti,
+		TextInput:        ti,
+		ExportRangeInput: ri,
+		ImportPathInput:  pi,
 
 		// Theme
 		CurrentTheme: currentTheme,
@@ -128,52 +213,260 @@ func (m Model) Init() tea.Cmd {
 	)
 }
 
+// exportRange returns the days the export dialog's scope covers
+func (m Model) exportRange() (domain.DateRange, error) {
+	day := m.SelectedDate
+	switch m.ExportScope {
+	case ExportCurrentWeek:
+		start := day.StartOfWeek()
+		return domain.DateRange{From: start.String(), To: start.AddDays(6).String()}, nil
+	case ExportCurrentMonth:
+		return domain.DateRange{From: day.FirstDayOfMonth().String(), To: day.LastDayOfMonth().String()}, nil
+	case ExportRange:
+		return domain.ParseDateRange(m.ExportRangeInput.Value())
+	case ExportAll:
+		return domain.DateRange{}, nil
+	default:
+		return domain.SingleDay(day.String()), nil
+	}
+}
+
+// exportTasks returns a command that writes the export to the export folder
+func (m Model) exportTasks(msg ExportMsg) tea.Cmd {
+	tasks := deepCopyTaskTree(m.Tasks)
+	timeline := deepCopyTimeline(m.Timeline)
+	colors := m.CurrentTheme.Colors
+	opts := storage.ExportOptions{CSV: m.Settings.CSVOptions(), Colors: &colors, Content: msg.Content}
+	return func() tea.Msg {
+		store, err := storage.NewStorage()
+		if err != nil {
+			return ExportedMsg{Error: err}
+		}
+		format := msg.Format.storageFormat()
+		name := msg.Dates.FileName()
+		filename := fmt.Sprintf("seyal-%s.%s", name, format.Extension())
+		if msg.Content == storage.ContentTimeline {
+			filename = fmt.Sprintf("seyal-%s-timeline.%s", name, format.Extension())
+		}
+		if msg.Template != nil {
+			opts.Template = msg.Template
+			base := strings.TrimSuffix(msg.Template.Name, filepath.Ext(msg.Template.Name))
+			filename = fmt.Sprintf("seyal-%s-%s.%s", name, base, msg.Template.Extension())
+		}
+		path, err := store.ExportToFile(tasks, timeline, format, msg.Dates, opts, filename)
+		return ExportedMsg{Path: path, Error: err}
+	}
+}
+
+// loadExportTemplates returns a command that lists the user's export
+// templates for the export dialog
+func (m Model) loadExportTemplates() tea.Cmd {
+	return func() tea.Msg {
+		dir, err := storage.GetTemplatePath()
+		if err != nil {
+			return ExportTemplatesLoadedMsg{Error: err}
+		}
+		templates, err := storage.ExportTemplates()
+		return ExportTemplatesLoadedMsg{Templates: templates, Dir: dir, Error: err}
+	}
+}
+
+// standupReport renders the standup dialog's report
+func (m Model) standupReport() string {
+	st := domain.BuildStandup(m.Tasks, m.Timeline, m.StandupDate)
+	if m.StandupMarkdown {
+		return storage.StandupMarkdown(st)
+	}
+	return storage.StandupText(st)
+}
+
+// copyStandup returns a command that copies the standup to the clipboard
+func (m Model) copyStandup() tea.Cmd {
+	report := m.standupReport()
+	return func() tea.Msg {
+		return StandupDoneMsg{Error: clipboard.WriteAll(report)}
+	}
+}
+
+// exportStandup returns a command that writes the standup to the export
+// folder
+func (m Model) exportStandup() tea.Cmd {
+	report := m.standupReport()
+	ext := "txt"
+	if m.StandupMarkdown {
+		ext = "md"
+	}
+	filename := fmt.Sprintf("seyal-standup-%s.%s", m.StandupDate, ext)
+	return func() tea.Msg {
+		path, err := storage.WriteExport(filename, report)
+		return StandupDoneMsg{Path: path, Error: err}
+	}
+}
+
+// readImport returns a command that reads a file for the import dialog.
+// Tasks the file does not place on a day go on the selected day.
+func (m Model) readImport(path string) tea.Cmd {
+	date := m.SelectedDate.String()
+	return func() tea.Msg {
+		if rest, ok := strings.CutPrefix(path, "~"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, rest)
+			}
+		}
+		format, ok := storage.ImportFormatForPath(path)
+		if !ok {
+			return ImportLoadedMsg{Path: path, Error: fmt.Errorf("unknown file type %q", filepath.Ext(path))}
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return ImportLoadedMsg{Path: path, Error: err}
+		}
+		defer f.Close()
+
+		store, err := storage.NewStorage()
+		if err != nil {
+			return ImportLoadedMsg{Path: path, Error: err}
+		}
+		data, err := store.Import(f, format, date)
+		return ImportLoadedMsg{Path: path, Data: data, Error: err}
+	}
+}
+
 // loadData returns a command to load saved data
 func (m Model) loadData() tea.Cmd {
 	return func() tea.Msg {
 		store, err := storage.NewStorage()
 		if err != nil {
-			// Return empty data on error
-			return LoadedMsg{
-				Tasks:    make(domain.TaskTree),
-				Timeline: make(domain.Timeline),
-				Theme:    "ultraviolet",
-			}
+			return LoadedMsg{Error: err}
 		}
 
 		schema, err := store.Load()
 		if err != nil {
-			return LoadedMsg{
-				Tasks:    make(domain.TaskTree),
-				Timeline: make(domain.Timeline),
-				Theme:    "ultraviolet",
+			// Never start from empty data here: the first save would
+			// replace the user's file
+			msg := LoadedMsg{Error: err}
+			var parseErr *storage.ParseError
+			if errors.As(err, &parseErr) && m.ReadOnly == "" {
+				if path, qerr := store.Quarantine(); qerr == nil {
+					msg.Quarantined = path
+				}
+			}
+			if backups, _ := store.Backups(); len(backups) > 0 {
+				msg.Backup = backups[0]
+			}
+			return msg
+		}
+
+		msg := LoadedMsg{
+			Tasks:       schema.Tasks,
+			Timeline:    schema.Timeline,
+			Recurrences: schema.Recurrences,
+			Theme:       schema.Settings.Theme,
+			Settings:    schema.Settings,
+		}
+		if schema.IsNewer() {
+			msg.ReadOnly = (&storage.VersionError{Path: store.DataPath, Version: schema.Version}).Error()
+		}
+		return msg
+	}
+}
+
+// SetReadOnly stops the model from saving, showing reason instead
+func (m *Model) SetReadOnly(reason string) {
+	m.ReadOnly = reason
+}
+
+// restoreBackup returns a command that replaces the data file with a backup
+func (m Model) restoreBackup(path string) tea.Cmd {
+	return func() tea.Msg {
+		store, err := storage.NewStorage()
+		if err == nil {
+			err = store.RestoreBackup(path)
+		}
+		return BackupRestoredMsg{Path: path, Error: err}
+	}
+}
+
+// loadBackups returns a command that reads every backup and compares it
+// with the current tasks
+func (m Model) loadBackups() tea.Cmd {
+	current := deepCopyTaskTree(m.Tasks)
+	return func() tea.Msg {
+		store, err := storage.NewStorage()
+		if err != nil {
+			return BackupsLoadedMsg{Error: err}
+		}
+		paths, err := store.Backups()
+		if err != nil {
+			return BackupsLoadedMsg{Error: err}
+		}
+
+		entries := make([]BackupEntry, 0, len(paths))
+		for _, path := range paths {
+			entry := BackupEntry{Path: path}
+			entry.Time, _ = store.BackupTime(path)
+			schema, err := store.LoadBackup(path)
+			if err != nil {
+				entry.Error = err
+				entries = append(entries, entry)
+				continue
+			}
+			for _, tasks := range schema.Tasks {
+				entry.Tasks += len(domain.FlattenTasks(tasks, 0, false))
+			}
+			for _, events := range schema.Timeline {
+				entry.Events += len(events)
 			}
+			if dates := schema.Tasks.Dates(); len(dates) > 0 {
+				entry.FirstDate, entry.LastDate = dates[0], dates[len(dates)-1]
+			}
+			entry.Diff = domain.DiffTaskTrees(current, schema.Tasks)
+			entries = append(entries, entry)
 		}
+		return BackupsLoadedMsg{Backups: entries}
+	}
+}
 
-		return LoadedMsg{
-			Tasks:    schema.Tasks,
-			Timeline: schema.Timeline,
-			Theme:    schema.Settings.Theme,
+// loadBackup returns a command that reads a backup for restoring
+func (m Model) loadBackup(path string) tea.Cmd {
+	return func() tea.Msg {
+		store, err := storage.NewStorage()
+		if err != nil {
+			return BackupLoadedMsg{Path: path, Error: err}
+		}
+		schema, err := store.LoadBackup(path)
+		if err != nil {
+			return BackupLoadedMsg{Path: path, Error: err}
 		}
+		return BackupLoadedMsg{Path: path, Tasks: schema.Tasks, Timeline: schema.Timeline, Recurrences: schema.Recurrences}
 	}
 }
 
 // saveData saves the current state to disk
 func (m Model) saveData() tea.Cmd {
+	if m.ReadOnly != "" {
+		return func() tea.Msg {
+			return SavedMsg{Success: false, Error: errors.New(m.ReadOnly)}
+		}
+	}
+	if !m.DataLoaded {
+		return func() tea.Msg {
+			return SavedMsg{Success: false, Error: errors.New("data file was not loaded")}
+		}
+	}
 	return func() tea.Msg {
 		store, err := storage.NewStorage()
 		if err != nil {
 			return SavedMsg{Success: false, Error: err}
 		}
 
+		settings := m.Settings
+		settings.Theme = m.CurrentTheme.Name
 		schema := &storage.StorageSchema{
-			Tasks:    m.Tasks,
-			Timeline: m.Timeline,
-			Settings: storage.Settings{
-				Theme:      "ultraviolet",
-				DateFormat: "January 2, 2006",
-				TimeFormat: "12h",
-			},
+			Tasks:       m.Tasks,
+			Timeline:    m.Timeline,
+			Recurrences: m.Recurrences,
+			Settings:    settings,
 		}
 
 		err = store.Save(schema)
@@ -181,18 +474,71 @@ func (m Model) saveData() tea.Cmd {
 	}
 }
 
+// readVault returns a command that reads the checkboxes changed in the
+// vault's daily notes since they were last written
+func (m Model) readVault() tea.Cmd {
+	dir := m.Settings.Vault
+	tasks := deepCopyTaskTree(m.Tasks)
+	return func() tea.Msg {
+		store, err := storage.NewStorage()
+		if err != nil {
+			return VaultReadMsg{Error: err}
+		}
+		report, err := store.Vault(dir).Read(tasks)
+		return VaultReadMsg{Report: report, Error: err}
+	}
+}
+
+// writeVault returns a command that brings the vault's daily notes up to
+// date with the tasks
+func (m Model) writeVault() tea.Cmd {
+	dir := m.Settings.Vault
+	tasks := deepCopyTaskTree(m.Tasks)
+	return func() tea.Msg {
+		store, err := storage.NewStorage()
+		if err != nil {
+			return VaultWrittenMsg{Error: err}
+		}
+		conflicts, err := store.Vault(dir).Write(tasks)
+		return VaultWrittenMsg{Conflicts: conflicts, Error: err}
+	}
+}
+
+// vaultNotice describes a failed vault sync for the hints line
+func vaultNotice(conflicts []storage.VaultConflict, err error) string {
+	switch {
+	case err != nil:
+		return "Vault: " + err.Error()
+	case len(conflicts) == 1:
+		return "Vault conflict in " + conflicts[0].String()
+	case len(conflicts) > 1:
+		return fmt.Sprintf("Vault: %d conflicts, first in %s. Run 'seyal vault' for all.", len(conflicts), conflicts[0])
+	}
+	return ""
+}
+
 // UpdateFlattenedTasks updates the flattened task list for rendering
 func (m *Model) UpdateFlattenedTasks() {
-	tasks := m.Tasks.GetTasksForDate(m.SelectedDate.String())
-	m.FlattenedTasks = domain.FlattenTasks(tasks, 0, true)
+	m.expandRecurrences()
+	if m.IsFiltering && m.FilterType == FilterDue {
+		// Deadlines are listed across all days, one row per task
+		m.FlattenedTasks = nil
+		days, _ := strconv.Atoi(m.FilterValue)
+		for _, task := range m.Tasks.DueWithin(domain.Today(), days) {
+			m.FlattenedTasks = append(m.FlattenedTasks, domain.FlattenedTask{Task: task})
+		}
+	} else {
+		tasks := m.tasksForDate(m.SelectedDate.String())
+		m.FlattenedTasks = domain.FlattenTasks(tasks, 0, true)
+	}
 
 	// Apply search filter
 	if m.IsSearching && m.SearchQuery != "" {
 		m.FlattenedTasks = m.filterTasksBySearch(m.FlattenedTasks)
 	}
 
-	// Apply state/priority filter
-	if m.IsFiltering && m.FilterValue != "" {
+	// Apply state/priority/tag filter
+	if m.IsFiltering && m.FilterValue != "" && m.FilterType != FilterDue {
 		m.FlattenedTasks = m.filterTasksByType(m.FlattenedTasks)
 	}
 
@@ -202,6 +548,32 @@ func (m *Model) UpdateFlattenedTasks() {
 	}
 }
 
+// expandRecurrences makes the occurrences of repeating tasks up to the
+// lookahead. Later days the calendar or task pane show get theirs in
+// Upcoming instead, for display only, so browsing ahead changes nothing.
+func (m *Model) expandRecurrences() {
+	m.Recurrences.Expand(m.Tasks, domain.RecurrenceHorizon())
+
+	// The calendar shows six weeks from the Sunday before the 1st
+	first := m.ViewingMonth.FirstDayOfMonth()
+	through := first.AddDays(41 - int(first.Weekday())).String()
+	if day := m.SelectedDate.String(); day > through {
+		through = day
+	}
+	m.Upcoming = m.Recurrences.Upcoming(m.Tasks, through)
+}
+
+// tasksForDate returns a day's tasks followed by its upcoming occurrences
+func (m Model) tasksForDate(date string) []*domain.Task {
+	return slices.Concat(m.Tasks.GetTasksForDate(date), m.Upcoming.GetTasksForDate(date))
+}
+
+// isUpcoming reports whether task is an occurrence that is only shown.
+// There is nothing to change until it is made.
+func (m Model) isUpcoming(task *domain.Task) bool {
+	return task != nil && m.Upcoming.FindTask(task.ID) == task
+}
+
 func (m *Model) filterTasksBySearch(tasks []domain.FlattenedTask) []domain.FlattenedTask {
 	var filtered []domain.FlattenedTask
 	query := m.SearchQuery
@@ -221,6 +593,10 @@ func (m *Model) filterTasksByType(tasks []domain.FlattenedTask) []domain.Flatten
 			if string(ft.Task.State) == m.FilterValue {
 				filtered = append(filtered, ft)
 			}
+		case FilterTag:
+			if ft.Task.HasTag(m.FilterValue) {
+				filtered = append(filtered, ft)
+			}
 		case FilterPriority:
 			// Priority filter: "1", "2", "3"
 			var targetPriority domain.TaskPriority
@@ -252,8 +628,9 @@ func (m *Model) GetSelectedTask() *domain.Task {
 func (m *Model) PushUndo() {
 	// Deep copy tasks and timeline
 	state := UndoState{
-		Tasks:    deepCopyTaskTree(m.Tasks),
-		Timeline: deepCopyTimeline(m.Timeline),
+		Tasks:       deepCopyTaskTree(m.Tasks),
+		Timeline:    deepCopyTimeline(m.Timeline),
+		Recurrences: deepCopyRecurrences(m.Recurrences),
 	}
 	m.UndoStack = append(m.UndoStack, state)
 	if len(m.UndoStack) > m.MaxUndo {
@@ -270,6 +647,7 @@ func (m *Model) PopUndo() bool {
 	m.UndoStack = m.UndoStack[:len(m.UndoStack)-1]
 	m.Tasks = state.Tasks
 	m.Timeline = state.Timeline
+	m.Recurrences = state.Recurrences
 	m.UpdateFlattenedTasks()
 	m.IsDirty = true
 	return true
@@ -351,3 +729,16 @@ func deepCopyTimeline(tl domain.Timeline) domain.Timeline {
 	}
 	return copy
 }
+
+func deepCopyRecurrences(rs domain.Recurrences) domain.Recurrences {
+	copy := make(domain.Recurrences, len(rs))
+	for i, r := range rs {
+		rCopy := *r
+		rCopy.Rule.ByDay = slices.Clone(r.Rule.ByDay)
+		rCopy.Rule.ByMonthDay = slices.Clone(r.Rule.ByMonthDay)
+		rCopy.Rule.ByMonth = slices.Clone(r.Rule.ByMonth)
+		rCopy.Template = deepCopyTask(r.Template)
+		copy[i] = &rCopy
+	}
+	return copy
+}
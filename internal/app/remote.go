@@ -0,0 +1,194 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/ipc"
+	"github.com/krisk248/seyal/internal/storage"
+)
+
+// ServeSocket listens on the per-user socket and forwards commands from the
+// CLI and other tools to the running program. Close the returned listener
+// when the program exits.
+func ServeSocket(p *tea.Program) (io.Closer, error) {
+	ln, err := ipc.Listen()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // Listener closed
+			}
+			go serveConn(p, conn)
+		}
+	}()
+
+	return ln, nil
+}
+
+// serveConn handles a single request/response exchange
+func serveConn(p *tea.Program, conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var resp ipc.Response
+	var req ipc.Request
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		resp = ipc.Response{Error: fmt.Sprintf("invalid request: %v", err)}
+	} else {
+		// The model resolves the command inside Update, where reading
+		// task state is safe
+		reply := make(chan ipc.Response, 1)
+		p.Send(RemoteCommandMsg{Request: req, Reply: reply})
+		select {
+		case resp = <-reply:
+		case <-time.After(5 * time.Second):
+			resp = ipc.Response{Error: "seyal TUI did not respond"}
+		}
+	}
+
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// handleRemoteCommand turns a socket request into the message the TUI would
+// send for the same action
+func (m Model) handleRemoteCommand(req ipc.Request) (tea.Cmd, ipc.Response) {
+	fail := func(format string, a ...any) (tea.Cmd, ipc.Response) {
+		return nil, ipc.Response{Error: fmt.Sprintf(format, a...)}
+	}
+
+	if m.LoadError != nil {
+		return fail("seyal could not load its data file: %v", m.LoadError)
+	}
+	if m.ReadOnly != "" {
+		return fail("seyal TUI is read-only: %s", m.ReadOnly)
+	}
+	// Anything saved before the first load would overwrite the data file
+	if !m.DataLoaded {
+		return fail("seyal is still loading, try again")
+	}
+
+	if req.Command == ipc.CommandReload {
+		return m.loadData(), ipc.Response{OK: true}
+	}
+
+	if req.Command == ipc.CommandAdd {
+		if req.Title == "" {
+			return fail("title is required")
+		}
+		if req.Date == "" {
+			req.Date = domain.Today().String()
+		}
+		if _, err := domain.ParseCalendarDate(req.Date); err != nil {
+			return fail("%v", err)
+		}
+		if req.ParentID != "" && m.Tasks.FindTask(req.ParentID) == nil {
+			return fail("parent task %q not found", req.ParentID)
+		}
+		if req.Priority != nil && !validPriority(*req.Priority) {
+			return fail("invalid priority %d", *req.Priority)
+		}
+		if req.Due != "" {
+			if _, err := domain.ParseCalendarDate(req.Due); err != nil {
+				return fail("due date: %v", err)
+			}
+		}
+		var repeat *domain.Rule
+		if req.Repeat != "" {
+			if req.ParentID != "" {
+				return fail("subtasks cannot repeat")
+			}
+			rule, err := domain.ParseRRule(req.Repeat)
+			if err != nil {
+				return fail("%v", err)
+			}
+			repeat = &rule
+		}
+
+		task := domain.NewTask(req.Title, req.Date)
+		if req.TaskID != "" {
+			task.ID = req.TaskID
+		}
+		task.Tags = req.Tags
+		task.DueDate = req.Due
+		if req.Priority != nil {
+			task.Priority = domain.TaskPriority(*req.Priority)
+		}
+		msg := TaskAddedMsg{Task: task, ParentID: req.ParentID, Repeat: repeat}
+		return func() tea.Msg { return msg }, ipc.Response{OK: true, TaskID: task.ID}
+	}
+
+	if req.Command == ipc.CommandImport {
+		if len(req.Tasks) == 0 && len(req.Events) == 0 {
+			return fail("no tasks to import")
+		}
+		for _, task := range req.Tasks {
+			if _, err := domain.ParseCalendarDate(task.Date); err != nil {
+				return fail("%s: %v", task.Title, err)
+			}
+		}
+		msg := ImportMsg{Data: &storage.ImportData{Tasks: req.Tasks, Events: req.Events}}
+		return func() tea.Msg { return msg }, ipc.Response{OK: true}
+	}
+
+	task := m.Tasks.FindTask(req.TaskID)
+	if task == nil {
+		return fail("task %q not found", req.TaskID)
+	}
+	resp := ipc.Response{OK: true, TaskID: task.ID}
+
+	var msg tea.Msg
+	switch req.Command {
+	case ipc.CommandState:
+		state, err := domain.ParseTaskState(req.State)
+		if err != nil {
+			return fail("%v", err)
+		}
+		msg = TaskStateChangedMsg{Task: task, PrevState: task.State, NewState: state}
+	case ipc.CommandPush:
+		msg = TaskPushedMsg{Task: task}
+	case ipc.CommandStart:
+		if task.IsRunning() {
+			return fail("task is already running")
+		}
+		msg = TaskStartedMsg{Task: task}
+	case ipc.CommandStop:
+		if !task.IsRunning() {
+			return fail("task is not running")
+		}
+		msg = TaskStoppedMsg{Task: task}
+	case ipc.CommandUpdate:
+		var cmds []tea.Cmd
+		if req.Title != "" {
+			update := TaskUpdatedMsg{Task: task, Title: req.Title, Tags: req.Tags}
+			cmds = append(cmds, func() tea.Msg { return update })
+		}
+		if req.Priority != nil {
+			if !validPriority(*req.Priority) {
+				return fail("invalid priority %d", *req.Priority)
+			}
+			priority := TaskPriorityChangedMsg{Task: task, Priority: domain.TaskPriority(*req.Priority)}
+			cmds = append(cmds, func() tea.Msg { return priority })
+		}
+		return tea.Sequence(cmds...), resp
+	case ipc.CommandDelete:
+		msg = TaskDeletedMsg{TaskID: task.ID}
+	default:
+		return fail("unknown command %q", req.Command)
+	}
+	return func() tea.Msg { return msg }, resp
+}
+
+func validPriority(p int) bool {
+	return p >= int(domain.PriorityNone) && p <= int(domain.PriorityLow)
+}
@@ -1,10 +1,16 @@
 package app
 
 import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/storage"
 )
 
 // Update handles all messages and updates the model
@@ -21,12 +27,50 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case LoadedMsg:
+		if msg.Error != nil {
+			m.LoadError = msg.Error
+			m.QuarantinePath = msg.Quarantined
+			m.LatestBackup = msg.Backup
+			return m, nil
+		}
+		m.LoadError = nil
+		m.QuarantinePath = ""
+		m.LatestBackup = ""
+		if msg.ReadOnly != "" {
+			m.SetReadOnly(msg.ReadOnly)
+		}
 		m.Tasks = msg.Tasks
 		m.Timeline = msg.Timeline
+		m.Recurrences = msg.Recurrences
+		m.Settings = msg.Settings
+		m.DataLoaded = true
 		if msg.Theme != "" {
 			m.SetTheme(msg.Theme)
 		}
 		m.UpdateFlattenedTasks()
+		if m.Settings.Vault != "" && m.ReadOnly == "" {
+			return m, m.readVault()
+		}
+		return m, nil
+
+	case VaultReadMsg:
+		if msg.Error != nil {
+			m.VaultNotice = vaultNotice(nil, msg.Error)
+			return m, nil
+		}
+		m.VaultNotice = vaultNotice(msg.Report.Conflicts, nil)
+		if len(msg.Report.Changes) == 0 {
+			return m, m.writeVault()
+		}
+		// Saving writes the notes back
+		m.PushUndo()
+		msg.Report.Apply(m.Tasks, m.Timeline)
+		m.UpdateFlattenedTasks()
+		m.IsDirty = true
+		return m, m.saveData()
+
+	case VaultWrittenMsg:
+		m.VaultNotice = vaultNotice(msg.Conflicts, msg.Error)
 		return m, nil
 
 	case DateSelectedMsg:
@@ -38,24 +82,61 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case TaskAddedMsg:
 		m.PushUndo()
-		m.Tasks.AddTask(msg.Task)
+		var parent *domain.Task
+		if msg.ParentID != "" {
+			parent = m.Tasks.FindTask(msg.ParentID)
+		}
+		// Add task and its timeline event
+		domain.AddNewTask(m.Tasks, m.Timeline, msg.Task, parent)
+		if msg.Repeat != nil && parent == nil {
+			m.Recurrences, _ = m.Recurrences.Repeat(m.Tasks, msg.Task, *msg.Repeat)
+		}
 		m.UpdateFlattenedTasks()
 		m.IsDirty = true
-		// Add timeline event
-		event := domain.NewTimelineEvent(msg.Task.ID, msg.Task.Title, domain.EventCreated)
-		m.Timeline.AddEvent(m.SelectedDate.String(), event)
 		return m, m.saveData()
 
-	case TaskStateChangedMsg:
+	case TaskUpdatedMsg:
 		m.PushUndo()
-		msg.Task.SetState(msg.NewState)
+		if series := m.Recurrences.Of(msg.Task); msg.Future && series != nil {
+			// The later occurrences keep their UpdatedAt: they still match
+			// the template, so a new rule may replace them
+			series.UpdateFuture(m.Tasks, msg.Task, func(t *domain.Task) {
+				t.Title = msg.Title
+				t.Tags = slices.Clone(msg.Tags)
+			})
+		}
+		msg.Task.Title = msg.Title
+		msg.Task.Tags = msg.Tags
+		msg.Task.UpdatedAt = time.Now()
 		m.UpdateFlattenedTasks()
 		m.IsDirty = true
-		// Add timeline event only for meaningful state changes
-		event := domain.NewStateChangeEvent(msg.Task.ID, msg.Task.Title, msg.PrevState, msg.NewState)
-		if event != nil {
-			m.Timeline.AddEvent(m.SelectedDate.String(), event)
+		return m, m.saveData()
+
+	case TaskDueChangedMsg:
+		m.PushUndo()
+		msg.Task.DueDate = msg.DueDate
+		msg.Task.UpdatedAt = time.Now()
+		m.UpdateFlattenedTasks()
+		m.IsDirty = true
+		return m, m.saveData()
+
+	case TaskRepeatMsg:
+		m.PushUndo()
+		if msg.Rule == nil {
+			m.Recurrences = m.Recurrences.Stop(m.Tasks, msg.Task)
+		} else {
+			m.Recurrences, _ = m.Recurrences.Repeat(m.Tasks, msg.Task, *msg.Rule)
 		}
+		m.UpdateFlattenedTasks()
+		m.IsDirty = true
+		return m, m.saveData()
+
+	case TaskStateChangedMsg:
+		m.PushUndo()
+		// Timeline event is added only for meaningful state changes
+		domain.ChangeTaskState(m.Timeline, msg.Task, msg.NewState)
+		m.UpdateFlattenedTasks()
+		m.IsDirty = true
 		return m, m.saveData()
 
 	case TaskPriorityChangedMsg:
@@ -67,40 +148,60 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case TaskPushedMsg:
 		m.PushUndo()
-		task := msg.Task
-		currentDate := m.SelectedDate.String()
-		nextDate := m.SelectedDate.AddDays(1).String()
-
-		// Increment pushed count
-		task.PushedCount++
-		task.UpdatedAt = time.Now()
-
-		// Remove from current day
-		m.Tasks.RemoveTask(currentDate, task.ID)
-
-		// Update task date and add to next day
-		task.Date = nextDate
-		m.Tasks.AddTask(task)
+		// Moves the task to the next day and logs a pushed event
+		if _, err := domain.PushTask(m.Tasks, m.Timeline, msg.Task); err != nil {
+			return m, func() tea.Msg { return ErrorMsg{Error: err} }
+		}
+		m.UpdateFlattenedTasks()
+		m.IsDirty = true
+		return m, m.saveData()
 
-		// Add timeline event for pushed task
-		event := domain.NewTimelineEvent(task.ID, task.Title, domain.EventPushed)
-		m.Timeline.AddEvent(currentDate, event)
+	case TaskStartedMsg:
+		m.PushUndo()
+		// Start timer and add started event
+		domain.StartTask(m.Timeline, msg.Task)
+		m.UpdateFlattenedTasks()
+		m.IsDirty = true
+		return m, m.saveData()
 
+	case TaskStoppedMsg:
+		m.PushUndo()
+		msg.Task.Stop()
 		m.UpdateFlattenedTasks()
 		m.IsDirty = true
 		return m, m.saveData()
 
 	case TaskDeletedMsg:
 		m.PushUndo()
-		m.Tasks.RemoveTask(m.SelectedDate.String(), msg.TaskID)
+		// Remote deletes may target a task on a day other than the selected one
+		date := m.SelectedDate.String()
+		if task := m.Tasks.FindTask(msg.TaskID); task != nil {
+			date = task.Date
+		}
+		m.Tasks.RemoveTask(date, msg.TaskID)
 		// Keep timeline events for deleted tasks as historical log
 		m.UpdateFlattenedTasks()
 		m.IsDirty = true
 		return m, m.saveData()
 
+	case RemoteCommandMsg:
+		cmd, resp := m.handleRemoteCommand(msg.Request)
+		msg.Reply <- resp
+		return m, cmd
+
+	case BackupRestoredMsg:
+		if msg.Error != nil {
+			m.LoadError = fmt.Errorf("restoring %s: %w", filepath.Base(msg.Path), msg.Error)
+			return m, nil
+		}
+		return m, m.loadData()
+
 	case SavedMsg:
 		if msg.Success {
 			m.IsDirty = false
+			if m.Settings.Vault != "" {
+				return m, m.writeVault()
+			}
 		}
 		return m, nil
 
@@ -112,6 +213,42 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case ExportMsg:
+		m.ExportResult = ""
+		m.ExportError = nil
+		return m, m.exportTasks(msg)
+
+	case ExportTemplatesLoadedMsg:
+		m.ExportTemplates = msg.Templates
+		m.ExportTemplateDir = msg.Dir
+		if m.SelectedTemplate >= len(m.ExportTemplates) {
+			m.SelectedTemplate = 0
+		}
+		if msg.Error != nil {
+			m.ExportError = fmt.Errorf("reading templates: %w", msg.Error)
+		}
+		if m.ExportFormat == ExportUserTemplate && len(m.ExportTemplates) == 0 {
+			m.ExportFormat = ExportMarkdown
+		}
+		return m, nil
+
+	case StandupDoneMsg:
+		m.StandupError = msg.Error
+		switch {
+		case msg.Error != nil:
+			m.StandupResult = ""
+		case msg.Path != "":
+			m.StandupResult = "Exported to " + msg.Path
+		default:
+			m.StandupResult = "Copied to the clipboard"
+		}
+		return m, nil
+
+	case ExportedMsg:
+		m.ExportResult = msg.Path
+		m.ExportError = msg.Error
+		return m, nil
+
 	case ThemeChangedMsg:
 		m.SetTheme(msg.ThemeName)
 		return m, nil
@@ -129,9 +266,55 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case UndoMsg:
-		m.PopUndo()
+		if m.PopUndo() {
+			return m, m.saveData()
+		}
+		return m, nil
+
+	case BackupsLoadedMsg:
+		m.Backups = msg.Backups
+		m.BackupsError = msg.Error
+		m.SelectedBackup = 0
 		return m, nil
 
+	case BackupLoadedMsg:
+		if msg.Error != nil {
+			m.BackupsError = fmt.Errorf("reading %s: %w", filepath.Base(msg.Path), msg.Error)
+			return m, nil
+		}
+		// One undo step brings back the data from before the restore
+		m.PushUndo()
+		m.Tasks = msg.Tasks
+		m.Timeline = msg.Timeline
+		m.Recurrences = msg.Recurrences
+		m.ActiveDialog = DialogNone
+		m.SelectedTaskIndex = 0
+		m.TaskScrollOffset = 0
+		m.UpdateFlattenedTasks()
+		return m, m.saveData()
+
+	case ImportLoadedMsg:
+		if msg.Error != nil {
+			m.ImportError = fmt.Errorf("reading %s: %w", msg.Path, msg.Error)
+			m.ImportPathInput.Focus()
+			return m, nil
+		}
+		// Preview on a copy; nothing changes until the import is confirmed
+		m.ImportData = msg.Data
+		m.ImportPreview = storage.MergeImport(deepCopyTaskTree(m.Tasks), make(domain.Timeline), msg.Data)
+		return m, nil
+
+	case ImportMsg:
+		m.PushUndo()
+		storage.MergeImport(m.Tasks, m.Timeline, msg.Data)
+		if m.ActiveDialog == DialogImport {
+			m.ActiveDialog = DialogNone
+		}
+		m.ImportData = nil
+		m.UpdateFlattenedTasks()
+		m.IsDirty = true
+		return m, m.saveData()
+
 	case ErrorMsg:
 		// TODO: Display error
 		return m, nil
@@ -151,6 +334,11 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	// Nothing else is usable until the data file loads
+	if m.LoadError != nil {
+		return m.handleLoadErrorKeys(msg)
+	}
+
 	// Handle dialogs first
 	if m.ActiveDialog != DialogNone {
 		return m.handleDialogKeys(msg)
@@ -166,6 +354,11 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleSearchMode(msg)
 	}
 
+	// Handle the key picking a filter
+	if m.CurrentMode == ModeFilter {
+		return m.handleFilterMode(msg)
+	}
+
 	// Handle help screen - allow exit with ? or Esc
 	if m.ShowHelp {
 		switch msg.String() {
@@ -205,7 +398,38 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case "ctrl+e":
-		return m, func() tea.Msg { return ToggleDialogMsg{Dialog: DialogExport} }
+		m.ExportResult = ""
+		m.ExportError = nil
+		return m, tea.Batch(
+			func() tea.Msg { return ToggleDialogMsg{Dialog: DialogExport} },
+			m.loadExportTemplates(),
+		)
+
+	case "ctrl+o":
+		m.ActiveDialog = DialogImport
+		m.ImportData = nil
+		m.ImportError = nil
+		m.ImportPathInput.Focus()
+		return m, nil
+
+	case "ctrl+s":
+		m.ActiveDialog = DialogStandup
+		m.StandupDate = m.SelectedDate
+		m.StandupResult = ""
+		m.StandupError = nil
+		return m, nil
+
+	case "#":
+		m.ActiveDialog = DialogTags
+		m.TagCounts = m.Tasks.TagCounts()
+		m.SelectedTag = 0
+		return m, nil
+
+	case "ctrl+b":
+		m.ActiveDialog = DialogBackups
+		m.Backups = nil
+		m.BackupsError = nil
+		return m, m.loadBackups()
 
 	case "/":
 		m.CurrentMode = ModeSearch
@@ -216,6 +440,9 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "1":
 		if m.ActivePane == PaneTasks && m.GetSelectedTask() != nil {
 			task := m.GetSelectedTask()
+			if m.isUpcoming(task) {
+				return m, nil
+			}
 			return m, func() tea.Msg {
 				return TaskPriorityChangedMsg{Task: task, Priority: domain.PriorityHigh}
 			}
@@ -226,6 +453,9 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "2":
 		if m.ActivePane == PaneTasks && m.GetSelectedTask() != nil {
 			task := m.GetSelectedTask()
+			if m.isUpcoming(task) {
+				return m, nil
+			}
 			return m, func() tea.Msg {
 				return TaskPriorityChangedMsg{Task: task, Priority: domain.PriorityMed}
 			}
@@ -236,6 +466,9 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "3":
 		if m.ActivePane == PaneTasks && m.GetSelectedTask() != nil {
 			task := m.GetSelectedTask()
+			if m.isUpcoming(task) {
+				return m, nil
+			}
 			return m, func() tea.Msg {
 				return TaskPriorityChangedMsg{Task: task, Priority: domain.PriorityLow}
 			}
@@ -317,6 +550,14 @@ func (m Model) handleCalendarKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 // handleTaskKeys handles task pane keyboard input
 func (m Model) handleTaskKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// Upcoming occurrences can be looked at, not changed
+	switch msg.String() {
+	case "e", "r", "u", "d", " ", "D", "x", "s", "0", "n":
+		if m.isUpcoming(m.GetSelectedTask()) {
+			return m, nil
+		}
+	}
+
 	switch msg.String() {
 	case "j", "down":
 		if m.SelectedTaskIndex < len(m.FlattenedTasks)-1 {
@@ -338,10 +579,33 @@ func (m Model) handleTaskKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Edit selected task
 		if task := m.GetSelectedTask(); task != nil {
 			m.CurrentMode = ModeInput
-			m.TextInput.SetValue(task.Title)
+			m.TextInput.SetValue(task.TitleWithTags())
 			m.TextInput.Focus()
 			m.EditingTask = task
 		}
+	case "r":
+		// Make the selected task repeat, or change its rule
+		if task := m.GetSelectedTask(); task != nil && task.ParentID == "" {
+			m.CurrentMode = ModeInput
+			m.TextInput.SetValue("")
+			if series := m.Recurrences.Of(task); series != nil {
+				m.TextInput.SetValue(series.Describe())
+			}
+			m.TextInput.CursorEnd()
+			m.TextInput.Focus()
+			m.EditingTask = task
+			m.Input = InputRepeat
+		}
+	case "u":
+		// Set or clear the selected task's due date
+		if task := m.GetSelectedTask(); task != nil {
+			m.CurrentMode = ModeInput
+			m.TextInput.SetValue(task.DueDate)
+			m.TextInput.CursorEnd()
+			m.TextInput.Focus()
+			m.EditingTask = task
+			m.Input = InputDue
+		}
 	case "d":
 		// Delete selected task
 		if task := m.GetSelectedTask(); task != nil {
@@ -386,17 +650,10 @@ func (m Model) handleTaskKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "s":
 		// Start/stop task
 		if task := m.GetSelectedTask(); task != nil {
-			m.PushUndo()
 			if task.IsRunning() {
-				task.Stop()
-			} else {
-				task.Start()
-				// Add started event
-				event := domain.NewTimelineEvent(task.ID, task.Title, domain.EventStarted)
-				m.Timeline.AddEvent(m.SelectedDate.String(), event)
+				return m, func() tea.Msg { return TaskStoppedMsg{Task: task} }
 			}
-			m.IsDirty = true
-			return m, m.saveData()
+			return m, func() tea.Msg { return TaskStartedMsg{Task: task} }
 		}
 	case "enter", "right":
 		// Expand/collapse
@@ -463,6 +720,14 @@ func (m Model) handleTimelineKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 // handleDialogKeys handles keyboard input when a dialog is open
 func (m Model) handleDialogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// Typing a range must not trigger the dialog shortcuts
+	if m.ActiveDialog == DialogExport && m.ExportRangeInput.Focused() {
+		return m.handleExportRangeInput(msg)
+	}
+	if m.ActiveDialog == DialogImport && m.ImportPathInput.Focused() {
+		return m.handleImportPathInput(msg)
+	}
+
 	switch msg.String() {
 	case "esc", "q":
 		m.ActiveDialog = DialogNone
@@ -485,11 +750,151 @@ func (m Model) handleDialogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Close on any key
 		m.ActiveDialog = DialogNone
 		return m, nil
+	case DialogBackups:
+		return m.handleBackupsDialogKeys(msg)
+	case DialogImport:
+		return m.handleImportDialogKeys(msg)
+	case DialogStandup:
+		return m.handleStandupDialogKeys(msg)
+	case DialogTags:
+		return m.handleTagsDialogKeys(msg)
+	case DialogEditScope:
+		return m.handleEditScopeDialogKeys(msg)
 	}
 
 	return m, nil
 }
 
+// handleStandupDialogKeys handles the standup dialog
+func (m Model) handleStandupDialogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "h", "left":
+		m.StandupDate = m.StandupDate.AddDays(-1)
+	case "l", "right":
+		m.StandupDate = m.StandupDate.AddDays(1)
+	case "m":
+		m.StandupMarkdown = !m.StandupMarkdown
+	case "c", "y":
+		return m, m.copyStandup()
+	case "e":
+		return m, m.exportStandup()
+	default:
+		return m, nil
+	}
+	m.StandupResult = ""
+	m.StandupError = nil
+	return m, nil
+}
+
+// handleTagsDialogKeys handles the tags dialog
+func (m Model) handleTagsDialogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "j", "down":
+		if m.SelectedTag < len(m.TagCounts)-1 {
+			m.SelectedTag++
+		}
+	case "k", "up":
+		if m.SelectedTag > 0 {
+			m.SelectedTag--
+		}
+	case "enter":
+		if m.SelectedTag >= len(m.TagCounts) {
+			return m, nil
+		}
+		tag := m.TagCounts[m.SelectedTag].Tag
+		m.ActiveDialog = DialogNone
+		m.SelectedTaskIndex = 0
+		m.TaskScrollOffset = 0
+		return m, func() tea.Msg { return FilterMsg{FilterType: FilterTag, Value: tag} }
+	}
+	return m, nil
+}
+
+// handleEditScopeDialogKeys asks whether an edit of an occurrence also
+// applies to the later occurrences of its series
+func (m Model) handleEditScopeDialogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.PendingUpdate == nil {
+		m.ActiveDialog = DialogNone
+		return m, nil
+	}
+	update := *m.PendingUpdate
+	switch msg.String() {
+	case "t", "enter":
+	case "f":
+		update.Future = true
+	default:
+		return m, nil
+	}
+	m.ActiveDialog = DialogNone
+	m.PendingUpdate = nil
+	return m, func() tea.Msg { return update }
+}
+
+// handleBackupsDialogKeys handles the backups dialog
+func (m Model) handleBackupsDialogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "j", "down":
+		if m.SelectedBackup < len(m.Backups)-1 {
+			m.SelectedBackup++
+		}
+	case "k", "up":
+		if m.SelectedBackup > 0 {
+			m.SelectedBackup--
+		}
+	case "enter", "r":
+		if m.SelectedBackup >= len(m.Backups) || m.ReadOnly != "" {
+			return m, nil
+		}
+		entry := m.Backups[m.SelectedBackup]
+		if entry.Error != nil {
+			return m, nil
+		}
+		return m, m.loadBackup(entry.Path)
+	}
+	return m, nil
+}
+
+// handleImportPathInput handles typing the path of the file to import
+func (m Model) handleImportPathInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.ImportPathInput.Blur()
+		m.ActiveDialog = DialogNone
+		return m, nil
+	case "enter":
+		path := strings.TrimSpace(m.ImportPathInput.Value())
+		if path == "" {
+			return m, nil
+		}
+		m.ImportPathInput.Blur()
+		m.ImportData = nil
+		m.ImportError = nil
+		return m, m.readImport(path)
+	}
+	var cmd tea.Cmd
+	m.ImportPathInput, cmd = m.ImportPathInput.Update(msg)
+	return m, cmd
+}
+
+// handleImportDialogKeys handles the import preview
+func (m Model) handleImportDialogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter", "y":
+		p := m.ImportPreview
+		if m.ImportData == nil || len(p.Added)+len(p.Updated)+len(p.Events) == 0 || m.ReadOnly != "" {
+			return m, nil
+		}
+		data := m.ImportData
+		return m, func() tea.Msg { return ImportMsg{Data: data} }
+	case "e", "tab":
+		m.ImportData = nil
+		m.ImportError = nil
+		m.ImportPathInput.Focus()
+		return m, nil
+	}
+	return m, nil
+}
+
 // handleThemeDialogKeys handles theme selection dialog
 func (m Model) handleThemeDialogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	themes := []string{"ultraviolet", "terminal", "minimal", "nord"}
@@ -508,10 +913,131 @@ func (m Model) handleThemeDialogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 // handleExportDialogKeys handles export dialog
 func (m Model) handleExportDialogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	// TODO: Implement export dialog navigation
+	switch msg.String() {
+	case "1":
+		m.ExportFormat = ExportMarkdown
+	case "2":
+		m.ExportFormat = ExportJSON
+	case "3":
+		m.ExportFormat = ExportPlainText
+	case "4":
+		m.ExportFormat = ExportICalendar
+	case "5":
+		m.ExportFormat = ExportTodoTxt
+	case "6":
+		m.ExportFormat = ExportTaskwarrior
+	case "7":
+		m.ExportFormat = ExportCSV
+	case "8":
+		m.ExportFormat = ExportHTML
+	case "9":
+		m.ExportFormat = ExportOrg
+	case "t":
+		if len(m.ExportTemplates) == 0 {
+			return m, nil
+		}
+		// Pressed again, t moves on to the next template
+		if m.ExportFormat == ExportUserTemplate {
+			m.SelectedTemplate = (m.SelectedTemplate + 1) % len(m.ExportTemplates)
+		}
+		m.ExportFormat = ExportUserTemplate
+	case "l":
+		if !m.ExportFormat.hasTimeline() {
+			return m, nil
+		}
+		m.ExportContent = (m.ExportContent + 1) % (storage.ContentTimeline + 1)
+	case "s", "f":
+		if m.ExportFormat != ExportCSV {
+			return m, nil
+		}
+		opts := m.Settings.CSVOptions()
+		if msg.String() == "s" {
+			opts.Delimiter = nextCSVDelimiter(opts)
+		} else {
+			opts.RFC4180 = !opts.RFC4180
+		}
+		m.Settings.CSV = &opts
+		m.IsDirty = true // Saved with the next change
+	case "d":
+		m.ExportScope = ExportCurrentDay
+	case "w":
+		m.ExportScope = ExportCurrentWeek
+	case "m":
+		m.ExportScope = ExportCurrentMonth
+	case "a":
+		m.ExportScope = ExportAll
+	case "r":
+		m.ExportScope = ExportRange
+		if m.ExportRangeInput.Value() == "" {
+			m.ExportRangeInput.SetValue(m.SelectedDate.String() + "..")
+			m.ExportRangeInput.CursorEnd()
+		}
+		m.ExportRangeInput.Focus()
+	case "enter":
+		return m.confirmExport()
+	default:
+		return m, nil
+	}
+	// Changing a choice clears the previous result
+	m.ExportResult = ""
+	m.ExportError = nil
 	return m, nil
 }
 
+// nextCSVDelimiter returns the delimiter after the current one in
+// storage.CSVDelimiters
+func nextCSVDelimiter(opts storage.CSVOptions) string {
+	current := opts.Delimiter
+	if current == "" {
+		current = storage.DefaultCSVDelimiter()
+	}
+	for i, d := range storage.CSVDelimiters {
+		if d == current {
+			return storage.CSVDelimiters[(i+1)%len(storage.CSVDelimiters)]
+		}
+	}
+	return storage.CSVDelimiters[0]
+}
+
+// handleExportRangeInput handles typing the custom export range
+func (m Model) handleExportRangeInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		// Keep typing after a mistake
+		if _, err := m.exportRange(); err != nil {
+			m.ExportError = err
+			return m, nil
+		}
+		m.ExportRangeInput.Blur()
+		return m.confirmExport()
+	case "esc", "tab":
+		m.ExportRangeInput.Blur()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.ExportRangeInput, cmd = m.ExportRangeInput.Update(msg)
+	m.ExportError = nil
+	return m, cmd
+}
+
+// confirmExport starts the export chosen in the dialog
+func (m Model) confirmExport() (tea.Model, tea.Cmd) {
+	dates, err := m.exportRange()
+	if err != nil {
+		m.ExportError = err
+		return m, nil
+	}
+	msg := ExportMsg{Format: m.ExportFormat, Scope: m.ExportScope, Dates: dates}
+	if m.ExportFormat.hasTimeline() {
+		msg.Content = m.ExportContent
+	}
+	if m.ExportFormat == ExportUserTemplate {
+		t := m.ExportTemplates[m.SelectedTemplate]
+		msg.Template = &t
+	}
+	return m, func() tea.Msg { return msg }
+}
+
 // handleClearTimelineDialogKeys handles clear timeline confirmation
 func (m Model) handleClearTimelineDialogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
@@ -534,24 +1060,40 @@ func (m Model) handleInputMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.CurrentMode = ModeNormal
 		m.TextInput.Blur()
 		m.EditingTask = nil
+		m.Input = InputTitle
+		m.InputError = nil
 		return m, nil
 	case "enter":
 		value := m.TextInput.Value()
+		switch m.Input {
+		case InputRepeat:
+			return m.confirmRepeat(strings.TrimSpace(value))
+		case InputDue:
+			return m.confirmDue(strings.TrimSpace(value))
+		case InputDueFilter:
+			return m.confirmDueFilter(strings.TrimSpace(value))
+		}
 		if value != "" {
 			if m.EditingTask != nil {
 				// Editing existing task
-				m.PushUndo()
-				m.EditingTask.Title = value
-				m.EditingTask.UpdatedAt = time.Now()
-				m.IsDirty = true
+				task := m.EditingTask
 				m.CurrentMode = ModeNormal
 				m.TextInput.Blur()
 				m.EditingTask = nil
-				m.UpdateFlattenedTasks()
-				return m, m.saveData()
+				title, tags := domain.ParseTags(value)
+				update := TaskUpdatedMsg{Task: task, Title: title, Tags: tags}
+				// An occurrence asks whether the later ones change too
+				if m.Recurrences.Of(task) != nil {
+					m.PendingUpdate = &update
+					m.ActiveDialog = DialogEditScope
+					return m, nil
+				}
+				return m, func() tea.Msg { return update }
 			} else {
-				// Creating new task
-				task := domain.NewTask(value, m.SelectedDate.String())
+				// Creating new task, "#tag" words become its tags
+				title, tags := domain.ParseTags(value)
+				task := domain.NewTask(title, m.SelectedDate.String())
+				task.Tags = tags
 				m.CurrentMode = ModeNormal
 				m.TextInput.Blur()
 				return m, func() tea.Msg { return TaskAddedMsg{Task: task} }
@@ -567,9 +1109,103 @@ func (m Model) handleInputMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	// Update text input
 	var cmd tea.Cmd
 	m.TextInput, cmd = m.TextInput.Update(msg)
+	m.InputError = nil
 	return m, cmd
 }
 
+// confirmRepeat makes the edited task repeat by the typed rule. An empty
+// rule stops the series after the task; a rule that does not parse keeps
+// the input open.
+func (m Model) confirmRepeat(value string) (tea.Model, tea.Cmd) {
+	task := m.EditingTask
+	series := m.Recurrences.Of(task)
+	repeat := TaskRepeatMsg{Task: task}
+	if value != "" {
+		rule, err := domain.ParseRule(value)
+		if err != nil {
+			m.InputError = err
+			return m, nil
+		}
+		repeat.Rule = &rule
+	}
+
+	m.CurrentMode = ModeNormal
+	m.TextInput.Blur()
+	m.EditingTask = nil
+	m.Input = InputTitle
+	m.InputError = nil
+	if series == nil && repeat.Rule == nil {
+		return m, nil
+	}
+	// Confirming the rule as shown changes nothing
+	if series != nil && repeat.Rule != nil {
+		if current, err := domain.ParseRule(series.Describe()); err == nil && current.String() == repeat.Rule.String() {
+			return m, nil
+		}
+	}
+	return m, func() tea.Msg { return repeat }
+}
+
+// confirmDue sets the edited task's due date to the typed day. An empty
+// value clears it; a day that does not parse keeps the input open.
+func (m Model) confirmDue(value string) (tea.Model, tea.Cmd) {
+	task := m.EditingTask
+	var due string
+	if value != "" {
+		var err error
+		if due, err = domain.ParseDueDate(value, domain.Today()); err != nil {
+			m.InputError = err
+			return m, nil
+		}
+	}
+
+	m.CurrentMode = ModeNormal
+	m.TextInput.Blur()
+	m.EditingTask = nil
+	m.Input = InputTitle
+	m.InputError = nil
+	if due == task.DueDate {
+		return m, nil
+	}
+	return m, func() tea.Msg { return TaskDueChangedMsg{Task: task, DueDate: due} }
+}
+
+// confirmDueFilter lists the tasks due within the typed number of days
+func (m Model) confirmDueFilter(value string) (tea.Model, tea.Cmd) {
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		m.InputError = fmt.Errorf("invalid number of days %q", value)
+		return m, nil
+	}
+
+	m.CurrentMode = ModeNormal
+	m.TextInput.Blur()
+	m.Input = InputTitle
+	m.InputError = nil
+	m.SelectedTaskIndex = 0
+	m.TaskScrollOffset = 0
+	return m, func() tea.Msg { return FilterMsg{FilterType: FilterDue, Value: strconv.Itoa(days)} }
+}
+
+// handleFilterMode reads the key after f, which picks the filter
+func (m Model) handleFilterMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.CurrentMode = ModeNormal
+	switch msg.String() {
+	case "u":
+		// Due within N days, across all days
+		value := "7"
+		if m.IsFiltering && m.FilterType == FilterDue {
+			value = m.FilterValue
+		}
+		m.CurrentMode = ModeInput
+		m.Input = InputDueFilter
+		m.TextInput.SetValue(value)
+		m.TextInput.CursorEnd()
+		m.TextInput.Focus()
+	}
+	return m, nil
+}
+
 // handleSearchMode handles search mode
 func (m Model) handleSearchMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
@@ -619,3 +1255,27 @@ func (m *Model) visibleTimelineRows() int {
 	availableLines := max(5, m.Height-10)
 	return max(1, availableLines/3)
 }
+
+// handleLoadErrorKeys handles keys on the load error screen
+func (m Model) handleLoadErrorKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "r":
+		// Like "n", restoring is safe only once the damaged file has been
+		// moved aside; otherwise it is the only copy of the current data
+		if m.LatestBackup != "" && m.QuarantinePath != "" {
+			return m, m.restoreBackup(m.LatestBackup)
+		}
+	case "n":
+		// Safe only once the damaged file has been moved out of the way
+		if m.QuarantinePath != "" {
+			return m.Update(LoadedMsg{
+				Tasks:    make(domain.TaskTree),
+				Timeline: make(domain.Timeline),
+				Settings: storage.DefaultSettings(),
+			})
+		}
+	}
+	return m, nil
+}
@@ -1,11 +1,15 @@
 package app
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"path/filepath"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/storage"
 )
 
 // View renders the entire application
@@ -14,6 +18,11 @@ func (m Model) View() string {
 		return "Loading..."
 	}
 
+	// Handle load errors (full screen)
+	if m.LoadError != nil {
+		return m.renderLoadErrorScreen()
+	}
+
 	// Handle help screen (full screen)
 	if m.ShowHelp {
 		return m.renderHelpScreen()
@@ -36,6 +45,7 @@ func (m Model) View() string {
 // renderMainLayout renders the three-pane layout
 func (m Model) renderMainLayout() string {
 	s := m.Styles
+	c := m.CurrentTheme.Colors
 
 	// Calculate widths
 	totalWidth := m.Width - 2 // Account for padding
@@ -85,6 +95,26 @@ func (m Model) renderMainLayout() string {
 	// Add keyboard hints at bottom
 	hints := m.renderKeyboardHints()
 
+	// Vault conflicts stay up until they are resolved
+	if m.VaultNotice != "" {
+		hints = lipgloss.NewStyle().Foreground(c.Warning).Render(m.VaultNotice)
+	}
+
+	// Occurrences past the lookahead are only shown
+	if task := m.GetSelectedTask(); m.ActivePane == PaneTasks && m.isUpcoming(task) {
+		made := "soon"
+		if day, err := domain.ParseCalendarDate(task.Date); err == nil {
+			made = "from " + day.AddDays(-domain.RecurrenceLookahead).Format("Mon Jan 2")
+		}
+		hints = lipgloss.NewStyle().Foreground(c.TextMuted).Render(fmt.Sprintf(
+			"Upcoming occurrence: it can be changed %s, %d weeks before its day", made, domain.RecurrenceLookahead/7))
+	}
+
+	// Warn that nothing will be saved in a second instance
+	if m.ReadOnly != "" {
+		hints = lipgloss.NewStyle().Foreground(c.Warning).Render("Read-only: "+m.ReadOnly+". Changes will not be saved.")
+	}
+
 	// Add exit confirmation if active
 	if m.ExitConfirm {
 		hints = s.Header.Render("Press Ctrl+C again or 'y' to exit, any other key to cancel")
@@ -133,12 +163,13 @@ func (m Model) renderCalendarPane(width, height int) string {
 
 	// Calendar grid
 	grid := domain.GenerateCalendarGrid(m.ViewingMonth)
+	dueDays := m.Tasks.DueDays()
 	for _, week := range grid.Weeks {
 		for _, day := range week {
 			isCurrentMonth := grid.IsCurrentMonth(day)
 			isSelected := day.Equals(m.SelectedDate)
 			isToday := day.IsToday()
-			hasTasks := len(m.Tasks.GetTasksForDate(day.String())) > 0
+			hasTasks := len(m.tasksForDate(day.String())) > 0
 
 			// Format day with brackets for selected
 			var dayStr string
@@ -163,6 +194,15 @@ func (m Model) renderCalendarPane(width, height int) string {
 				style = lipgloss.NewStyle().Foreground(c.TextPrimary)
 			}
 
+			// Deadline days end in a "!" instead of the padding
+			if dueDays[day.String()] > 0 && !isSelected {
+				mark := c.Warning
+				if day.String() < domain.Today().String() {
+					mark = c.Error
+				}
+				b.WriteString(style.Render(dayStr[:3]) + lipgloss.NewStyle().Foreground(mark).Bold(true).Render("!"))
+				continue
+			}
 			b.WriteString(style.Render(dayStr))
 		}
 		b.WriteString("\n")
@@ -201,7 +241,7 @@ func (m Model) renderTaskPane(width, height int) string {
 	b.WriteString(title + "\n")
 
 	// Date and stats
-	tasks := m.Tasks.GetTasksForDate(m.SelectedDate.String())
+	tasks := m.tasksForDate(m.SelectedDate.String())
 	total, completed := domain.GetTaskStats(tasks)
 	percentage := 0
 	if total > 0 {
@@ -215,11 +255,25 @@ func (m Model) renderTaskPane(width, height int) string {
 
 	// Input field if in input mode
 	if m.CurrentMode == ModeInput {
-		prompt := "> "
-		if m.EditingTask != nil {
+		prompt, hint := "> ", ""
+		switch {
+		case m.Input == InputRepeat:
+			prompt = "Repeat: "
+			hint = "daily, weekdays, weekly on mon, monthly on the 2nd tue or an RRULE; empty stops"
+		case m.Input == InputDue:
+			prompt = "Due: "
+			hint = "YYYY-MM-DD, today, tomorrow, fri or +3; empty clears"
+		case m.Input == InputDueFilter:
+			prompt = "Due within days: "
+		case m.EditingTask != nil:
 			prompt = "Edit: "
 		}
 		b.WriteString(prompt + m.TextInput.View() + "\n")
+		if m.InputError != nil {
+			b.WriteString(lipgloss.NewStyle().Foreground(c.Error).Render(m.InputError.Error()) + "\n")
+		} else if hint != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(c.TextMuted).Render(hint) + "\n")
+		}
 	}
 
 	// Search indicator
@@ -230,6 +284,9 @@ func (m Model) renderTaskPane(width, height int) string {
 	// Filter indicator
 	if m.IsFiltering {
 		filterStr := fmt.Sprintf("Filter: %s=%s", m.FilterType, m.FilterValue)
+		if m.FilterType == FilterDue {
+			filterStr = fmt.Sprintf("Filter: due within %s days, all dates", m.FilterValue)
+		}
 		b.WriteString(lipgloss.NewStyle().Foreground(c.Secondary).Render(filterStr) + "\n")
 	}
 
@@ -244,6 +301,7 @@ func (m Model) renderTaskPane(width, height int) string {
 		visibleRows--
 	}
 
+	today := domain.Today()
 	for i := startIdx; i < endIdx; i++ {
 		ft := m.FlattenedTasks[i]
 		task := ft.Task
@@ -286,8 +344,32 @@ func (m Model) renderTaskPane(width, height int) string {
 			pushedText = fmt.Sprintf(" [↷%d]", task.PushedCount)
 		}
 
+		// Repeat indicator text (for width calculation)
+		repeatText := ""
+		if task.SeriesID != "" {
+			repeatText = " ↻"
+		}
+
+		// Due date text (for width calculation); rows of other days, as the
+		// due filter lists, also show the day the task is planned for
+		dueText := ""
+		if due := task.DueText(today); due != "" {
+			dueText = " [" + due + "]"
+		}
+		if task.Date != m.SelectedDate.String() && ft.Depth == 0 {
+			if day, err := domain.ParseCalendarDate(task.Date); err == nil {
+				dueText += " " + day.Format("Mon Jan 2")
+			}
+		}
+
+		// Tag chips text (for width calculation)
+		tagsText := ""
+		for _, tag := range task.Tags {
+			tagsText += "  " + tag + " "
+		}
+
 		// Calculate available width for title (include all suffixes)
-		prefixLen := len(selector) + len(indent) + len(checkboxText) + len(priorityText) + len(expandIcon) + len(runningText) + len(pushedText)
+		prefixLen := len(selector) + len(indent) + len(checkboxText) + len(priorityText) + len(expandIcon) + len(runningText) + len(pushedText) + len(repeatText) + len(dueText) + len(tagsText)
 		availableWidth := width - prefixLen - 4 // margin
 
 		// Truncate title if needed (on plain text, before styling)
@@ -313,7 +395,25 @@ func (m Model) renderTaskPane(width, height int) string {
 			pushedIndicator = lipgloss.NewStyle().Foreground(c.Warning).Render(pushedText)
 		}
 
-		line := fmt.Sprintf("%s%s%s%s%s%s%s%s", selector, indent, checkbox, priority, expandIcon, title, runningIndicator, pushedIndicator)
+		// Repeat indicator (styled)
+		repeatIndicator := ""
+		if task.SeriesID != "" {
+			repeatIndicator = lipgloss.NewStyle().Foreground(c.Secondary).Render(repeatText)
+		}
+
+		// Due date indicator (styled)
+		dueIndicator := ""
+		if dueText != "" {
+			dueIndicator = lipgloss.NewStyle().Foreground(m.dueColor(task, today)).Render(dueText)
+		}
+
+		// Tag chips (styled)
+		tagChips := ""
+		for _, tag := range task.Tags {
+			tagChips += " " + m.renderTagChip(tag)
+		}
+
+		line := fmt.Sprintf("%s%s%s%s%s%s%s%s%s%s%s", selector, indent, checkbox, priority, expandIcon, title, repeatIndicator, dueIndicator, tagChips, runningIndicator, pushedIndicator)
 		b.WriteString(line + "\n")
 	}
 
@@ -391,6 +491,9 @@ func (m Model) renderTimelinePane(width, height int) string {
 
 		// Event description
 		desc := fmt.Sprintf("%s %s", event.GetEventDescription(), event.TaskTitle)
+		if event.Note != "" {
+			desc += ": " + event.Note
+		}
 		if len(desc) > width-18 {
 			desc = desc[:width-21] + "..."
 		}
@@ -504,7 +607,7 @@ func (m Model) renderKeyboardHints() string {
 		hintPairs = [][]string{
 			{"j/k", "nav"}, {"a", "add"}, {"e", "edit"}, {"d", "del"}, {"v", "details"},
 			{"Space", "done"}, {"D", "delegate"}, {"x", "delay"}, {"s", "start"},
-			{"n", "next day"}, {"/", "search"}, {"1/2/3", "priority"},
+			{"n", "next day"}, {"r", "repeat"}, {"u", "due"}, {"/", "search"}, {"1/2/3", "priority"},
 		}
 	case PaneTimeline:
 		hintPairs = [][]string{
@@ -543,6 +646,16 @@ func (m Model) renderWithDialog() string {
 		dialog = m.renderClearTimelineDialog()
 	case DialogTaskDetails:
 		dialog = m.renderTaskDetailsDialog()
+	case DialogBackups:
+		dialog = m.renderBackupsDialog()
+	case DialogImport:
+		dialog = m.renderImportDialog()
+	case DialogStandup:
+		dialog = m.renderStandupDialog()
+	case DialogTags:
+		dialog = m.renderTagsDialog()
+	case DialogEditScope:
+		dialog = m.renderEditScopeDialog()
 	}
 
 	// Center dialog on screen
@@ -555,6 +668,58 @@ func (m Model) renderWithDialog() string {
 	)
 }
 
+// renderLoadErrorScreen renders the recovery screen shown when the data
+// file could not be loaded
+func (m Model) renderLoadErrorScreen() string {
+	s := m.Styles
+	c := m.CurrentTheme.Colors
+	muted := lipgloss.NewStyle().Foreground(c.TextMuted)
+	key := lipgloss.NewStyle().Foreground(c.Secondary).Bold(true)
+
+	var b strings.Builder
+	b.WriteString(s.ModalTitle.Render("Could not load your tasks") + "\n\n")
+
+	var parseErr *storage.ParseError
+	if errors.As(m.LoadError, &parseErr) {
+		b.WriteString(fmt.Sprintf("%s is damaged at line %d, column %d:\n",
+			filepath.Base(parseErr.Path), parseErr.Line, parseErr.Column))
+		b.WriteString(lipgloss.NewStyle().Foreground(c.Error).Render(parseErr.Err.Error()) + "\n\n")
+
+		// Show the offending line with a marker under the column
+		snippet, col := parseErr.Snippet, parseErr.Column
+		if len(snippet) > 60 {
+			start := max(0, min(col-30, len(snippet)-60))
+			snippet, col = snippet[start:start+60], col-start
+		}
+		b.WriteString("  " + snippet + "\n")
+		b.WriteString("  " + strings.Repeat(" ", max(col-1, 0)) + lipgloss.NewStyle().Foreground(c.Error).Render("^") + "\n\n")
+	} else {
+		b.WriteString(lipgloss.NewStyle().Foreground(c.Error).Render(m.LoadError.Error()) + "\n\n")
+	}
+
+	if m.QuarantinePath != "" {
+		b.WriteString("The damaged file was moved to\n")
+		b.WriteString(muted.Render(m.QuarantinePath) + "\n")
+	}
+	b.WriteString("Nothing will be saved until this is resolved.\n\n")
+
+	if m.LatestBackup != "" && m.QuarantinePath != "" {
+		b.WriteString(key.Render("r") + " - Restore newest backup " + muted.Render(filepath.Base(m.LatestBackup)) + "\n")
+	}
+	if m.QuarantinePath != "" {
+		b.WriteString(key.Render("n") + " - Start with an empty task list\n")
+	}
+	b.WriteString(key.Render("q") + " - Quit\n")
+
+	return lipgloss.Place(
+		m.Width,
+		m.Height,
+		lipgloss.Center,
+		lipgloss.Center,
+		s.Dialog.Render(b.String()),
+	)
+}
+
 // renderHelpDialog renders the help dialog
 func (m Model) renderHelpDialog() string {
 	s := m.Styles
@@ -579,6 +744,10 @@ func (m Model) renderHelpDialog() string {
 				{"Ctrl+C", "Exit (press twice)"},
 				{"Ctrl+U", "Undo"},
 				{"Ctrl+E", "Export"},
+				{"Ctrl+O", "Import"},
+				{"Ctrl+S", "Standup report"},
+				{"Ctrl+B", "Backups"},
+				{"#", "Tags and tag filter"},
 				{"?", "This help"},
 				{":", "Month overview"},
 				{"L", "Jump to logs"},
@@ -610,6 +779,9 @@ func (m Model) renderHelpDialog() string {
 				{"x", "Toggle delayed"},
 				{"s", "Start/stop timer"},
 				{"n", "Push to next day"},
+				{"r", "Repeat task"},
+				{"u", "Set due date"},
+				{"f u", "Tasks due within N days"},
 				{"1/2/3", "Set priority P1/P2/P3"},
 				{"0", "Clear priority"},
 				{"Enter", "Expand/collapse"},
@@ -639,6 +811,231 @@ func (m Model) renderHelpDialog() string {
 	return s.Modal.Render(b.String())
 }
 
+// renderBackupsDialog renders the list of backups with what restoring the
+// selected one would change
+func (m Model) renderBackupsDialog() string {
+	s := m.Styles
+	c := m.CurrentTheme.Colors
+	muted := lipgloss.NewStyle().Foreground(c.TextMuted)
+
+	var b strings.Builder
+	b.WriteString(s.ModalTitle.Render("Backups") + "\n\n")
+
+	switch {
+	case m.BackupsError != nil && len(m.Backups) == 0:
+		b.WriteString(lipgloss.NewStyle().Foreground(c.Error).Render(m.BackupsError.Error()) + "\n\n")
+	case m.Backups == nil:
+		b.WriteString(muted.Render("Reading backups...") + "\n\n")
+	case len(m.Backups) == 0:
+		b.WriteString("No backups yet. One is taken on the first save of each day.\n\n")
+	}
+
+	// Show a window of rows around the selection
+	const visible = 10
+	start := max(0, min(m.SelectedBackup-visible/2, len(m.Backups)-visible))
+	end := min(len(m.Backups), start+visible)
+	for i := start; i < end; i++ {
+		entry := m.Backups[i]
+		when := filepath.Base(entry.Path)
+		if !entry.Time.IsZero() {
+			when = entry.Time.Format("Mon Jan 2 2006 15:04")
+		}
+
+		var info string
+		switch {
+		case entry.Error != nil:
+			info = lipgloss.NewStyle().Foreground(c.Error).Render("unreadable")
+		case entry.Tasks == 0:
+			info = muted.Render("no tasks")
+		default:
+			info = fmt.Sprintf("%3d tasks  %s", entry.Tasks, muted.Render(entry.FirstDate+" → "+entry.LastDate))
+		}
+
+		line := fmt.Sprintf("%-22s %s", when, info)
+		if i == m.SelectedBackup {
+			b.WriteString(lipgloss.NewStyle().Foreground(c.Primary).Bold(true).Render("▸ ") + line + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+
+	if m.SelectedBackup < len(m.Backups) {
+		entry := m.Backups[m.SelectedBackup]
+		b.WriteString("\n")
+		if entry.Error != nil {
+			b.WriteString(lipgloss.NewStyle().Foreground(c.Error).Render(entry.Error.Error()) + "\n")
+		} else {
+			d := entry.Diff
+			if d == (domain.TreeDiff{}) {
+				b.WriteString("Same tasks as now.\n")
+			} else {
+				b.WriteString(fmt.Sprintf("Restoring brings back %s tasks, drops %s and reverts %s.\n",
+					lipgloss.NewStyle().Foreground(c.Success).Render(fmt.Sprintf("%d", d.Added)),
+					lipgloss.NewStyle().Foreground(c.Error).Render(fmt.Sprintf("%d", d.Removed)),
+					lipgloss.NewStyle().Foreground(c.Warning).Render(fmt.Sprintf("%d", d.Changed))))
+			}
+			b.WriteString(muted.Render(fmt.Sprintf("%d timeline events · %s", entry.Events, filepath.Base(entry.Path))) + "\n")
+		}
+	}
+	if m.BackupsError != nil && len(m.Backups) > 0 {
+		b.WriteString(lipgloss.NewStyle().Foreground(c.Error).Render(m.BackupsError.Error()) + "\n")
+	}
+
+	b.WriteString("\n")
+	if m.ReadOnly != "" {
+		b.WriteString(muted.Render("Read-only: restoring is disabled · Esc to close"))
+	} else {
+		b.WriteString(muted.Render("j/k to choose, Enter to restore (Ctrl+U undoes it), Esc to close"))
+	}
+
+	return s.Modal.Render(b.String())
+}
+
+// renderImportDialog renders the import path input and, once the file is
+// read, what importing it would add
+func (m Model) renderImportDialog() string {
+	s := m.Styles
+	c := m.CurrentTheme.Colors
+	muted := lipgloss.NewStyle().Foreground(c.TextMuted)
+
+	var b strings.Builder
+	b.WriteString(s.ModalTitle.Render("Import Tasks") + "\n\n")
+	b.WriteString(lipgloss.NewStyle().Foreground(c.Primary).Render("File:") + "\n")
+	b.WriteString("  " + m.ImportPathInput.View() + "\n\n")
+
+	switch {
+	case m.ImportError != nil:
+		b.WriteString(lipgloss.NewStyle().Foreground(c.Error).Render(m.ImportError.Error()) + "\n\n")
+	case m.ImportData != nil:
+		p := m.ImportPreview
+		b.WriteString(fmt.Sprintf("Adds %s tasks, updates %s and skips %s duplicates.\n",
+			lipgloss.NewStyle().Foreground(c.Success).Render(fmt.Sprintf("%d", len(p.Added))),
+			lipgloss.NewStyle().Foreground(c.Secondary).Render(fmt.Sprintf("%d", len(p.Updated))),
+			lipgloss.NewStyle().Foreground(c.Warning).Render(fmt.Sprintf("%d", len(p.Duplicates)))))
+		if len(p.Events) > 0 {
+			b.WriteString(fmt.Sprintf("Adds %d notes to the timeline.\n", len(p.Events)))
+		}
+		for _, warning := range m.ImportData.Warnings {
+			b.WriteString(lipgloss.NewStyle().Foreground(c.Warning).Render("! "+warning) + "\n")
+		}
+
+		const visible = 8
+		for i, task := range p.Added {
+			if i == visible {
+				b.WriteString(muted.Render(fmt.Sprintf("  … and %d more", len(p.Added)-visible)) + "\n")
+				break
+			}
+			indent := ""
+			if task.ParentID != "" {
+				indent = "  "
+			}
+			b.WriteString("  " + indent + task.Title + " " + muted.Render(task.Date) + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	switch {
+	case m.ImportPathInput.Focused():
+		b.WriteString(muted.Render("Type the path of a .md, .ics, todo.txt, Taskwarrior .json or .org file, Enter to preview, Esc to close"))
+	case m.ReadOnly != "":
+		b.WriteString(muted.Render("Read-only: importing is disabled · Esc to close"))
+	case m.ImportData != nil && len(m.ImportPreview.Added)+len(m.ImportPreview.Updated)+len(m.ImportPreview.Events) > 0:
+		b.WriteString(muted.Render("Enter to import (Ctrl+U undoes it), e to change the file, Esc to close"))
+	default:
+		b.WriteString(muted.Render("e to change the file, Esc to close"))
+	}
+
+	return s.Modal.Render(b.String())
+}
+
+// renderStandupDialog renders the standup report for the dialog's day
+func (m Model) renderStandupDialog() string {
+	s := m.Styles
+	c := m.CurrentTheme.Colors
+	muted := lipgloss.NewStyle().Foreground(c.TextMuted)
+
+	var b strings.Builder
+	b.WriteString(s.ModalTitle.Render("Standup") + "\n\n")
+
+	// Leave room for the title, the status and the key hints
+	lines := strings.Split(strings.TrimRight(m.standupReport(), "\n"), "\n")
+	visible := max(5, m.Height-14)
+	if len(lines) > visible {
+		more := len(lines) - visible + 1
+		lines = append(lines[:visible-1], muted.Render(fmt.Sprintf("… %d more lines", more)))
+	}
+	b.WriteString(strings.Join(lines, "\n") + "\n\n")
+
+	switch {
+	case m.StandupError != nil:
+		b.WriteString(lipgloss.NewStyle().Foreground(c.Error).Render(m.StandupError.Error()) + "\n\n")
+	case m.StandupResult != "":
+		b.WriteString(lipgloss.NewStyle().Foreground(c.Success).Render(m.StandupResult) + "\n\n")
+	}
+
+	format := "plain text"
+	if m.StandupMarkdown {
+		format = "Markdown"
+	}
+	b.WriteString(muted.Render("←/→ day · m format: "+format+" · c copy · e export · Esc to close"))
+
+	return s.Modal.Render(b.String())
+}
+
+// renderTagsDialog renders the tag summary, from which a tag filters the
+// task list
+func (m Model) renderTagsDialog() string {
+	s := m.Styles
+	c := m.CurrentTheme.Colors
+	muted := lipgloss.NewStyle().Foreground(c.TextMuted)
+
+	var b strings.Builder
+	b.WriteString(s.ModalTitle.Render("Tags") + "\n\n")
+
+	if len(m.TagCounts) == 0 {
+		b.WriteString("No tags yet. Type #tag words when adding or editing a task.\n\n")
+		b.WriteString(muted.Render("Esc to close"))
+		return s.Modal.Render(b.String())
+	}
+
+	// Tasks on the selected day, the ones the filter would show
+	day := make(map[string]int)
+	for _, ft := range domain.FlattenTasks(m.Tasks.GetTasksForDate(m.SelectedDate.String()), 0, false) {
+		for _, tag := range ft.Task.Tags {
+			day[tag]++
+		}
+	}
+
+	width := 0
+	for _, count := range m.TagCounts {
+		width = max(width, len(count.Tag))
+	}
+
+	// Show a window of rows around the selection
+	const visible = 12
+	start := max(0, min(m.SelectedTag-visible/2, len(m.TagCounts)-visible))
+	end := min(len(m.TagCounts), start+visible)
+	for i := start; i < end; i++ {
+		count := m.TagCounts[i]
+		pad := strings.Repeat(" ", width-len(count.Tag))
+		days := fmt.Sprintf("%d days", count.Days)
+		if count.Days == 1 {
+			days = "1 day"
+		}
+		info := fmt.Sprintf("%3d open  %3d done  %s", count.Open, count.Completed,
+			muted.Render(fmt.Sprintf("%s · %d on this day", days, day[count.Tag])))
+		line := m.renderTagChip(count.Tag) + pad + "  " + info
+		if i == m.SelectedTag {
+			b.WriteString(lipgloss.NewStyle().Foreground(c.Primary).Bold(true).Render("▸ ") + line + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+
+	b.WriteString("\n" + muted.Render("j/k select · Enter filter the task list · Esc to close"))
+	return s.Modal.Render(b.String())
+}
+
 // renderThemeDialog renders the theme selection dialog
 func (m Model) renderThemeDialog() string {
 	s := m.Styles
@@ -681,25 +1078,104 @@ func (m Model) renderThemeDialog() string {
 func (m Model) renderExportDialog() string {
 	s := m.Styles
 	c := m.CurrentTheme.Colors
+	muted := lipgloss.NewStyle().Foreground(c.TextMuted)
+
+	// option renders one choice, marking the selected one
+	option := func(key, label string, selected bool) string {
+		marker := "  "
+		style := lipgloss.NewStyle().Foreground(c.TextPrimary)
+		if selected {
+			marker = lipgloss.NewStyle().Foreground(c.Success).Render("● ")
+			style = style.Bold(true)
+		}
+		return "  " + marker + lipgloss.NewStyle().Foreground(c.Secondary).Render(key) + ". " + style.Render(label) + "\n"
+	}
 
 	var b strings.Builder
 	b.WriteString(s.ModalTitle.Render("Export Tasks") + "\n\n")
 
 	b.WriteString(lipgloss.NewStyle().Foreground(c.Primary).Render("Format:") + "\n")
-	b.WriteString("  1. Markdown\n")
-	b.WriteString("  2. JSON\n")
-	b.WriteString("  3. Plain Text\n\n")
+	b.WriteString(option("1", "Markdown", m.ExportFormat == ExportMarkdown))
+	b.WriteString(option("2", "JSON", m.ExportFormat == ExportJSON))
+	b.WriteString(option("3", "Plain Text", m.ExportFormat == ExportPlainText))
+	if m.ExportFormat.hasTimeline() {
+		b.WriteString(muted.Render("       l content: "+m.ExportContent.String()) + "\n")
+	}
+	b.WriteString(option("4", "iCalendar (VTODO)", m.ExportFormat == ExportICalendar))
+	b.WriteString(option("5", "todo.txt", m.ExportFormat == ExportTodoTxt))
+	b.WriteString(option("6", "Taskwarrior JSON", m.ExportFormat == ExportTaskwarrior))
+	b.WriteString(option("7", "CSV timesheet", m.ExportFormat == ExportCSV))
+	if m.ExportFormat == ExportCSV {
+		opts := m.Settings.CSVOptions()
+		delimiter := opts.Delimiter
+		if delimiter == "" {
+			delimiter = storage.DefaultCSVDelimiter()
+		}
+		quoting := "when needed"
+		if opts.RFC4180 {
+			quoting = "every field (RFC 4180)"
+		}
+		b.WriteString(muted.Render(fmt.Sprintf("       s delimiter: %s · f quoting: %s", csvDelimiterName(delimiter), quoting)) + "\n")
+	}
+	b.WriteString(option("8", "HTML report", m.ExportFormat == ExportHTML))
+	b.WriteString(option("9", "Org-mode", m.ExportFormat == ExportOrg))
+	if len(m.ExportTemplates) > 0 {
+		t := m.ExportTemplates[m.SelectedTemplate]
+		label := "Template: " + t.Name
+		if len(m.ExportTemplates) > 1 {
+			label += fmt.Sprintf(" (%d/%d)", m.SelectedTemplate+1, len(m.ExportTemplates))
+		}
+		b.WriteString(option("t", label, m.ExportFormat == ExportUserTemplate))
+	} else if m.ExportTemplateDir != "" {
+		b.WriteString(muted.Render("     Templates: none in "+m.ExportTemplateDir) + "\n")
+	}
+	b.WriteString("\n")
 
 	b.WriteString(lipgloss.NewStyle().Foreground(c.Primary).Render("Scope:") + "\n")
-	b.WriteString("  d. Current day\n")
-	b.WriteString("  m. Current month\n")
-	b.WriteString("  a. All tasks\n\n")
+	b.WriteString(option("d", "Selected day", m.ExportScope == ExportCurrentDay))
+	b.WriteString(option("w", "Selected week (Mon-Sun)", m.ExportScope == ExportCurrentWeek))
+	b.WriteString(option("m", "Selected month", m.ExportScope == ExportCurrentMonth))
+	b.WriteString(option("r", "Date range", m.ExportScope == ExportRange))
+	if m.ExportScope == ExportRange {
+		b.WriteString("       " + m.ExportRangeInput.View() + "\n")
+	}
+	b.WriteString(option("a", "All tasks", m.ExportScope == ExportAll))
+	b.WriteString("\n")
+
+	if dates, err := m.exportRange(); err == nil {
+		b.WriteString(muted.Render("Days: ") + dates.String() + "\n\n")
+	}
 
-	b.WriteString(lipgloss.NewStyle().Foreground(c.TextMuted).Render("Press format+scope (e.g., '1d' for Markdown/Day), Esc to close"))
+	switch {
+	case m.ExportError != nil:
+		b.WriteString(lipgloss.NewStyle().Foreground(c.Error).Render(m.ExportError.Error()) + "\n\n")
+	case m.ExportResult != "":
+		b.WriteString(lipgloss.NewStyle().Foreground(c.Success).Render("Exported to ") + m.ExportResult + "\n\n")
+	}
+
+	if m.ExportRangeInput.Focused() {
+		b.WriteString(muted.Render("Type FROM..TO (either end may be left open), Enter to export, Tab to finish"))
+	} else {
+		b.WriteString(muted.Render("Choose a format and scope, Enter to export, Esc to close"))
+	}
 
 	return s.Modal.Render(b.String())
 }
 
+// csvDelimiterName spells out a CSV delimiter for the export dialog
+func csvDelimiterName(d string) string {
+	switch d {
+	case "\t":
+		return "tab"
+	case ";":
+		return "semicolon"
+	case ",":
+		return "comma"
+	default:
+		return fmt.Sprintf("%q", d)
+	}
+}
+
 // renderClearTimelineDialog renders the clear timeline confirmation
 func (m Model) renderClearTimelineDialog() string {
 	s := m.Styles
@@ -715,6 +1191,27 @@ func (m Model) renderClearTimelineDialog() string {
 	return s.Dialog.Render(b.String())
 }
 
+// renderEditScopeDialog asks whether an edit of a repeating task changes
+// only this occurrence or the later ones too
+func (m Model) renderEditScopeDialog() string {
+	s := m.Styles
+	c := m.CurrentTheme.Colors
+	key := lipgloss.NewStyle().Foreground(c.Secondary).Bold(true)
+
+	var b strings.Builder
+	b.WriteString(s.ModalTitle.Render("Edit Repeating Task") + "\n\n")
+	if m.PendingUpdate != nil {
+		if series := m.Recurrences.Of(m.PendingUpdate.Task); series != nil {
+			b.WriteString(lipgloss.NewStyle().Foreground(c.TextMuted).Render("Repeats "+series.Describe()) + "\n\n")
+		}
+	}
+	b.WriteString(key.Render("t") + " - This occurrence\n")
+	b.WriteString(key.Render("f") + " - This and all future occurrences\n")
+	b.WriteString(key.Render("Esc") + " - Cancel\n")
+
+	return s.Dialog.Render(b.String())
+}
+
 // renderTaskDetailsDialog renders the task details dialog
 func (m Model) renderTaskDetailsDialog() string {
 	s := m.Styles
@@ -766,6 +1263,32 @@ func (m Model) renderTaskDetailsDialog() string {
 	}
 	b.WriteString(priorityLabel + " " + priorityStyle.Render(priorityValue) + "\n")
 
+	// Tags (if any)
+	if len(task.Tags) > 0 {
+		tagsLabel := lipgloss.NewStyle().Foreground(c.Primary).Bold(true).Render("Tags:")
+		chips := make([]string, len(task.Tags))
+		for i, tag := range task.Tags {
+			chips[i] = m.renderTagChip(tag)
+		}
+		b.WriteString(tagsLabel + " " + strings.Join(chips, " ") + "\n")
+	}
+
+	// Due date (if any)
+	if due, err := domain.ParseCalendarDate(task.DueDate); err == nil {
+		dueLabel := lipgloss.NewStyle().Foreground(c.Primary).Bold(true).Render("Due:")
+		dueValue := due.Format("Mon Jan 2, 2006")
+		if text := task.DueText(domain.Today()); text != "" {
+			dueValue += " (" + text + ")"
+		}
+		b.WriteString(dueLabel + " " + lipgloss.NewStyle().Foreground(m.dueColor(task, domain.Today())).Render(dueValue) + "\n")
+	}
+
+	// Repeat rule (occurrences only)
+	if series := m.Recurrences.Of(task); series != nil {
+		repeatLabel := lipgloss.NewStyle().Foreground(c.Primary).Bold(true).Render("Repeats:")
+		b.WriteString(repeatLabel + " " + lipgloss.NewStyle().Foreground(c.Secondary).Render(series.Describe()) + "\n")
+	}
+
 	// Created date
 	createdLabel := lipgloss.NewStyle().Foreground(c.Primary).Bold(true).Render("Created:")
 	createdValue := task.CreatedAt.Format("Jan 2, 2006 3:04 PM")
@@ -848,6 +1371,10 @@ func (m Model) renderHelpScreen() string {
 				{"Ctrl+C", "Exit (press twice)"},
 				{"Ctrl+U", "Undo"},
 				{"Ctrl+E", "Export"},
+				{"Ctrl+O", "Import"},
+				{"Ctrl+S", "Standup report"},
+				{"Ctrl+B", "Backups"},
+				{"#", "Tags and tag filter"},
 				{"?", "Toggle help"},
 				{":", "Month overview"},
 				{"L", "Jump to logs"},
@@ -869,6 +1396,9 @@ func (m Model) renderHelpScreen() string {
 				{"x", "Toggle delayed"},
 				{"s", "Start/stop timer"},
 				{"n", "Push to next day"},
+				{"r", "Repeat task"},
+				{"u", "Set due date"},
+				{"f u", "Due within N days"},
 				{"1/2/3", "Set priority"},
 				{"0", "Clear priority"},
 				{"Enter", "Expand/collapse"},
@@ -1018,7 +1548,7 @@ func (m Model) renderOverview() string {
 
 			// Day header
 			dayStr := fmt.Sprintf("%d", day.Day)
-			tasks := m.Tasks.GetTasksForDate(day.String())
+			tasks := m.tasksForDate(day.String())
 			total, completed := domain.GetTaskStats(tasks)
 
 			headerStyle := lipgloss.NewStyle().Foreground(c.TextPrimary)
@@ -1132,6 +1662,33 @@ func (m Model) getPriorityText(task *domain.Task) string {
 	}
 }
 
+// dueColor colours a task's due date by how close it is
+func (m Model) dueColor(task *domain.Task, today domain.CalendarDate) lipgloss.Color {
+	c := m.CurrentTheme.Colors
+	days, _ := task.DaysUntilDue(today)
+	switch {
+	case task.State == domain.TaskStateCompleted:
+		return c.TextMuted
+	case days < 0:
+		return c.Error
+	case days <= 1:
+		return c.Warning
+	default:
+		return c.TextMuted
+	}
+}
+
+// renderTagChip renders a tag as a coloured chip. Each tag keeps its
+// colour wherever it is shown.
+func (m Model) renderTagChip(tag string) string {
+	c := m.CurrentTheme.Colors
+	palette := []lipgloss.Color{c.Primary, c.Secondary, c.Accent, c.Success, c.Warning, c.TaskDelegated}
+	h := fnv.New32a()
+	h.Write([]byte(tag))
+	color := palette[h.Sum32()%uint32(len(palette))]
+	return lipgloss.NewStyle().Background(color).Foreground(c.Background).Render(" " + tag + " ")
+}
+
 func (m Model) getPriorityIndicator(task *domain.Task) string {
 	c := m.CurrentTheme.Colors
 	switch task.Priority {
@@ -1153,6 +1710,9 @@ func (m Model) getTaskStyle(task *domain.Task, isSelected bool) lipgloss.Style {
 	if isSelected {
 		return s.TaskSelected
 	}
+	if m.isUpcoming(task) {
+		return lipgloss.NewStyle().Foreground(c.TextMuted)
+	}
 
 	switch task.State {
 	case domain.TaskStateCompleted:
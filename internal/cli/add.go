@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/ipc"
+	"github.com/krisk248/seyal/internal/storage"
+)
+
+// runAdd implements `seyal add`
+func runAdd(args []string) error {
+	fs := newFlagSet("add", `"title" [--date YYYY-MM-DD] [--priority 1|2|3] [--parent <id>] [--due DAY] [--repeat RULE]
+
+#words in a title become tags. Titles are read from stdin, one per line,
+when no title is given or the title is "-". --due takes YYYY-MM-DD, today,
+tomorrow, a weekday or +N days. --repeat takes a rule such as "weekdays",
+"weekly on mon, thu", "monthly on the last fri" or an RRULE like
+"FREQ=MONTHLY;BYDAY=2TU".`)
+	date := fs.String("date", domain.Today().String(), "day to schedule the task on (YYYY-MM-DD)")
+	priority := fs.String("priority", "", "priority: 1 (P1), 2 (P2) or 3 (P3)")
+	parent := fs.String("parent", "", "ID or unique ID prefix of the parent task")
+	due := fs.String("due", "", "day the task must be done by, separate from --date")
+	repeat := fs.String("repeat", "", "rule the task repeats by, from --date on")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if _, err := domain.ParseCalendarDate(*date); err != nil {
+		return usageError(fs, "%v", err)
+	}
+	prio, err := parsePriority(*priority)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	var dueDate string
+	if *due != "" {
+		if dueDate, err = domain.ParseDueDate(*due, domain.Today()); err != nil {
+			return usageError(fs, "%v", err)
+		}
+	}
+	var rule *domain.Rule
+	if *repeat != "" {
+		if *parent != "" {
+			return usageError(fs, "--repeat and --parent cannot be combined; subtasks repeat with their task")
+		}
+		r, err := domain.ParseRule(*repeat)
+		if err != nil {
+			return usageError(fs, "%v", err)
+		}
+		rule = &r
+	}
+
+	var titles []string
+	if len(positional) == 0 || (len(positional) == 1 && positional[0] == "-") {
+		titles, err = readTitles()
+		if err != nil {
+			return err
+		}
+	} else {
+		titles = []string{strings.Join(positional, " ")}
+	}
+	if len(titles) == 0 {
+		return usageError(fs, "no task title given")
+	}
+
+	store, err := storage.NewStorage()
+	if err != nil {
+		return err
+	}
+
+	var added []*domain.Task
+	var series []*domain.Recurrence
+	schema, err := update(store, func(schema *storage.StorageSchema) ([]ipc.Request, error) {
+		var parentTask *domain.Task
+		if *parent != "" {
+			parentTask, err = findTask(schema.Tasks, *parent)
+			if err != nil {
+				return nil, err
+			}
+			if flagPassed(fs, "date") && *date != parentTask.Date {
+				return nil, fmt.Errorf("--date %s conflicts with parent task date %s", *date, parentTask.Date)
+			}
+		}
+
+		added = make([]*domain.Task, 0, len(titles))
+		series = series[:0]
+		reqs := make([]ipc.Request, 0, len(titles))
+		for _, title := range titles {
+			title, tags := domain.ParseTags(title)
+			task := domain.NewTask(title, *date)
+			task.Tags = tags
+			task.DueDate = dueDate
+			if prio != domain.PriorityNone {
+				task.SetPriority(prio)
+			}
+			domain.AddNewTask(schema.Tasks, schema.Timeline, task, parentTask)
+			added = append(added, task)
+			if rule != nil {
+				var r *domain.Recurrence
+				schema.Recurrences, r = schema.Recurrences.Repeat(schema.Tasks, task, *rule)
+				r.Expand(schema.Tasks, domain.RecurrenceHorizon())
+				series = append(series, r)
+			}
+
+			req := ipc.Request{Command: ipc.CommandAdd, TaskID: task.ID, Title: task.Title, Date: task.Date, Tags: task.Tags, Due: task.DueDate}
+			if prio != domain.PriorityNone {
+				p := int(prio)
+				req.Priority = &p
+			}
+			if parentTask != nil {
+				req.ParentID = parentTask.ID
+			}
+			if rule != nil {
+				req.Repeat = rule.String()
+			}
+			reqs = append(reqs, req)
+		}
+		return reqs, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ids := shortIDs(schema.Tasks)
+	for i, task := range added {
+		if task.DueDate != "" {
+			fmt.Fprintf(Stdout, "Added %s %s (%s, due %s)\n", ids[task.ID], task.Title, task.Date, task.DueDate)
+		} else {
+			fmt.Fprintf(Stdout, "Added %s %s (%s)\n", ids[task.ID], task.Title, task.Date)
+		}
+		if i < len(series) {
+			fmt.Fprintf(Stdout, "  repeating %s\n", series[i].Describe())
+		}
+	}
+	return nil
+}
+
+// readTitles reads one task title per non-empty line from stdin
+func readTitles() ([]string, error) {
+	var titles []string
+	scanner := bufio.NewScanner(Stdin)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			titles = append(titles, line)
+		}
+	}
+	return titles, scanner.Err()
+}
+
+// parsePriority parses a priority flag value; empty means no priority
+func parsePriority(s string) (domain.TaskPriority, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "", "0":
+		return domain.PriorityNone, nil
+	case "1", "P1":
+		return domain.PriorityHigh, nil
+	case "2", "P2":
+		return domain.PriorityMed, nil
+	case "3", "P3":
+		return domain.PriorityLow, nil
+	default:
+		return domain.PriorityNone, fmt.Errorf("invalid priority %q (want 1, 2 or 3)", s)
+	}
+}
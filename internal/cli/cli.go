@@ -0,0 +1,206 @@
+// Package cli implements the headless seyal subcommands used for scripting
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/ipc"
+	"github.com/krisk248/seyal/internal/storage"
+)
+
+// command describes a single subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// commands lists every subcommand in the order shown by usage
+var commands []command
+
+func init() {
+	commands = []command{
+		{"add", "Add tasks without opening the TUI", runAdd},
+		{"list", "List tasks as a tree, JSON or TSV", runList},
+		{"done", "Mark tasks completed", stateCommand("done", setState(domain.TaskStateCompleted, "Completed"))},
+		{"delegate", "Mark tasks delegated", stateCommand("delegate", setState(domain.TaskStateDelegated, "Delegated"))},
+		{"delay", "Mark tasks delayed", stateCommand("delay", setState(domain.TaskStateDelayed, "Delayed"))},
+		{"start", "Start the timer on tasks", stateCommand("start", startTask)},
+		{"stop", "Stop the timer on tasks", stateCommand("stop", stopTask)},
+		{"push", "Push tasks to the next day", stateCommand("push", pushTask)},
+		{"serve", "Serve a local HTTP JSON API", runServe},
+		{"standup", "Print a standup report for a day", runStandup},
+		{"vault", "Sync tasks with the daily notes of a Markdown vault", runVault},
+		{"import", "Import tasks from Markdown, iCalendar, todo.txt, Taskwarrior or Org", runImport},
+		{"migrate", "Convert the data file to another storage backend", runMigrate},
+	}
+}
+
+// errUsage signals that the arguments were invalid and usage was printed
+var errUsage = errors.New("usage")
+
+// Stdin, Stdout and Stderr can be replaced when embedding the CLI
+var (
+	Stdin  io.Reader = os.Stdin
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+)
+
+// Run executes the subcommand in args and returns the process exit code
+func Run(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(Stdout)
+		return 0
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		err := c.run(args[1:])
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+			return 2
+		default:
+			fmt.Fprintf(Stderr, "seyal %s: %v\n", c.name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(Stderr, "seyal: unknown command %q\n\n", args[0])
+	printUsage(Stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: seyal [command] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run without a command to open the TUI.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'seyal <command> -h' for command flags.")
+}
+
+// newFlagSet creates a flag set that reports errors instead of exiting
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(Stderr, "Usage: seyal %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses flags that may appear before, between or after
+// positional arguments and returns the positional arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// usageError prints msg followed by the command usage
+func usageError(fs *flag.FlagSet, format string, a ...any) error {
+	fmt.Fprintf(Stderr, "seyal %s: %s\n", fs.Name(), fmt.Sprintf(format, a...))
+	fs.Usage()
+	return errUsage
+}
+
+// flagPassed reports whether the named flag was set on the command line
+func flagPassed(fs *flag.FlagSet, name string) bool {
+	passed := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+// loadSchema loads stored data for read-only commands
+func loadSchema(store *storage.Storage) (*storage.StorageSchema, error) {
+	schema, err := store.Load()
+	var parseErr *storage.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, err // Already names the file, line and column
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", store.DataPath, err)
+	}
+	return schema, nil
+}
+
+// update applies fn to the stored data and saves it under the data file
+// lock. When a running TUI holds the lock, fn runs on a copy instead and
+// the requests it returns are forwarded to the TUI, which keeps its
+// in-memory state authoritative. Only other holders, such as a second CLI
+// command, are waited out.
+func update(store *storage.Storage, fn func(schema *storage.StorageSchema) ([]ipc.Request, error)) (*storage.StorageSchema, error) {
+	var result *storage.StorageSchema
+	apply := func(schema *storage.StorageSchema) error {
+		result = schema
+		_, err := fn(schema)
+		return err
+	}
+	err := store.TryUpdate(apply)
+	var locked *storage.LockedError
+	if errors.As(err, &locked) && !ipc.Running() {
+		err = store.Update(apply)
+	}
+	if !errors.As(err, &locked) {
+		return result, err
+	}
+
+	schema, err := loadSchema(store)
+	if err != nil {
+		return nil, err
+	}
+	reqs, err := fn(schema)
+	if err != nil {
+		return nil, err
+	}
+	sent, err := sendToTUI(reqs)
+	if err != nil {
+		return nil, err
+	}
+	if !sent {
+		// Held by something other than a TUI we can talk to
+		return nil, locked
+	}
+	return schema, nil
+}
+
+// sendToTUI forwards requests to a running TUI. It returns false when no
+// TUI is listening.
+func sendToTUI(reqs []ipc.Request) (bool, error) {
+	for i, req := range reqs {
+		if _, err := ipc.Send(req); err != nil {
+			if i == 0 && errors.Is(err, ipc.ErrNotRunning) {
+				return false, nil
+			}
+			return true, err
+		}
+	}
+	return true, nil
+}
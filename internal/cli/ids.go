@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// findTask resolves a full task ID or a unique ID prefix anywhere in the tree
+func findTask(tasks domain.TaskTree, ref string) (*domain.Task, error) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	if ref == "" {
+		return nil, fmt.Errorf("empty task ID")
+	}
+
+	if task := tasks.FindTask(ref); task != nil {
+		return task, nil
+	}
+
+	var matches []*domain.Task
+	for _, dayTasks := range tasks {
+		for _, ft := range domain.FlattenTasks(dayTasks, 0, false) {
+			if strings.HasPrefix(ft.Task.ID, ref) {
+				matches = append(matches, ft.Task)
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no task matches ID %q", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("ID %q is ambiguous (%d tasks match)", ref, len(matches))
+	}
+}
+
+// minShortIDLength is the shortest prefix shown for a task ID
+const minShortIDLength = 6
+
+// shortIDs maps every task ID in the tree to its shortest unique prefix
+func shortIDs(tasks domain.TaskTree) map[string]string {
+	var ids []string
+	for _, dayTasks := range tasks {
+		for _, ft := range domain.FlattenTasks(dayTasks, 0, false) {
+			ids = append(ids, ft.Task.ID)
+		}
+	}
+	sort.Strings(ids)
+
+	result := make(map[string]string, len(ids))
+	for i, id := range ids {
+		n := minShortIDLength
+		if i > 0 {
+			n = max(n, commonPrefixLen(id, ids[i-1])+1)
+		}
+		if i < len(ids)-1 {
+			n = max(n, commonPrefixLen(id, ids[i+1])+1)
+		}
+		result[id] = id[:min(n, len(id))]
+	}
+	return result
+}
+
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
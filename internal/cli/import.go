@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/ipc"
+	"github.com/krisk248/seyal/internal/storage"
+)
+
+// runImport implements `seyal import`
+func runImport(args []string) error {
+	fs := newFlagSet("import", `[--format markdown|ical|todotxt|taskwarrior|org] [--date YYYY-MM-DD] [--dry-run] <file>
+
+The file is read from stdin when it is "-". The format is guessed from the
+file extension when --format is not given. Tasks with the ID of a stored
+task update it; other tasks whose title matches a task on the same day are
+skipped.`)
+	formatName := fs.String("format", "", "file format: markdown, ical, todotxt, taskwarrior or org")
+	date := fs.String("date", domain.Today().String(), "day for tasks the file does not place on a day (YYYY-MM-DD)")
+	dryRun := fs.Bool("dry-run", false, "show what would be imported without changing anything")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError(fs, "expected exactly one file")
+	}
+	path := positional[0]
+
+	if _, err := domain.ParseCalendarDate(*date); err != nil {
+		return usageError(fs, "%v", err)
+	}
+	format, err := importFormat(*formatName, path)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	var content []byte
+	if path == "-" {
+		content, err = io.ReadAll(Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.NewStorage()
+	if err != nil {
+		return err
+	}
+	data, err := store.Import(bytes.NewReader(content), format, *date)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	for _, warning := range data.Warnings {
+		fmt.Fprintf(Stderr, "seyal import: %s\n", warning)
+	}
+
+	if *dryRun {
+		schema, err := loadSchema(store)
+		if err != nil {
+			return err
+		}
+		report := storage.MergeImport(schema.Tasks, schema.Timeline, data)
+		printImportReport(report, nil, "Would add", "Would update", "Would skip duplicate")
+		fmt.Fprintf(Stdout, "Dry run: %d to add, %d to update, %d duplicates, %d notes\n", len(report.Added), len(report.Updated), len(report.Duplicates), len(report.Events))
+		return nil
+	}
+
+	var report storage.ImportReport
+	schema, err := update(store, func(schema *storage.StorageSchema) ([]ipc.Request, error) {
+		report = storage.MergeImport(schema.Tasks, schema.Timeline, data)
+		return []ipc.Request{{Command: ipc.CommandImport, Tasks: data.Tasks, Events: data.Events}}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	printImportReport(report, shortIDs(schema.Tasks), "Added", "Updated", "Skipped duplicate")
+	fmt.Fprintf(Stdout, "Imported %d tasks, updated %d, skipped %d duplicates, added %d notes\n", len(report.Added), len(report.Updated), len(report.Duplicates), len(report.Events))
+	return nil
+}
+
+// importFormat resolves the --format flag, falling back to the file
+// extension
+func importFormat(name, path string) (storage.ImportFormat, error) {
+	if name != "" {
+		return storage.ParseImportFormat(name)
+	}
+	if format, ok := storage.ImportFormatForPath(path); ok {
+		return format, nil
+	}
+	return 0, fmt.Errorf("cannot tell the format of %q, use --format", path)
+}
+
+// printImportReport prints one line per added, updated and skipped task.
+// Short IDs are left out when ids is nil.
+func printImportReport(report storage.ImportReport, ids map[string]string, added, updated, skipped string) {
+	id := func(task *domain.Task) string {
+		if ids == nil {
+			return ""
+		}
+		return ids[task.ID] + " "
+	}
+	for _, task := range report.Added {
+		fmt.Fprintf(Stdout, "%s %s%s (%s)\n", added, id(task), importPath(task, report.Added), task.Date)
+	}
+	for _, task := range report.Updated {
+		fmt.Fprintf(Stdout, "%s %s%s (%s)\n", updated, id(task), task.Title, task.Date)
+	}
+	for _, task := range report.Duplicates {
+		fmt.Fprintf(Stdout, "%s %s (%s)\n", skipped, task.Title, task.Date)
+	}
+}
+
+// importPath returns the task title prefixed by its parents' titles when
+// they were imported too
+func importPath(task *domain.Task, added []*domain.Task) string {
+	parts := []string{task.Title}
+	for parentID := task.ParentID; parentID != ""; {
+		var parent *domain.Task
+		for _, t := range added {
+			if t.ID == parentID {
+				parent = t
+				break
+			}
+		}
+		if parent == nil {
+			break
+		}
+		parts = append([]string{parent.Title}, parts...)
+		parentID = parent.ParentID
+	}
+	return strings.Join(parts, " > ")
+}
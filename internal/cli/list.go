@@ -0,0 +1,326 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/storage"
+)
+
+// listFilter holds the task predicates for `seyal list`
+type listFilter struct {
+	state       domain.TaskState
+	priority    domain.TaskPriority
+	hasPriority bool
+	search      string
+	tag         string
+	dueBy       string // Open tasks due on or before this day
+}
+
+// matches reports whether a single task passes every filter
+func (f listFilter) matches(task *domain.Task) bool {
+	if f.state != "" && task.State != f.state {
+		return false
+	}
+	if f.hasPriority && task.Priority != f.priority {
+		return false
+	}
+	if f.search != "" && !strings.Contains(strings.ToLower(task.Title), f.search) {
+		return false
+	}
+	if f.tag != "" && !task.HasTag(f.tag) {
+		return false
+	}
+	if f.dueBy != "" && (task.DueDate == "" || task.DueDate > f.dueBy || task.State == domain.TaskStateCompleted) {
+		return false
+	}
+	return true
+}
+
+// listedTask is a task selected for output, with its depth in the tree.
+// Matched is false for ancestors that are only shown for context.
+type listedTask struct {
+	Task    *domain.Task
+	Depth   int
+	Matched bool
+}
+
+// listItem is the machine-readable form of a listed task
+type listItem struct {
+	ID          string              `json:"id"`
+	ShortID     string              `json:"shortId"`
+	Date        string              `json:"date"`
+	Title       string              `json:"title"`
+	State       domain.TaskState    `json:"state"`
+	Priority    domain.TaskPriority `json:"priority"`
+	ParentID    string              `json:"parentId,omitempty"`
+	Depth       int                 `json:"depth"`
+	PushedCount int                 `json:"pushedCount"`
+	Tags        []string            `json:"tags,omitempty"`
+	DueDate     string              `json:"dueDate,omitempty"`
+	SeriesID    string              `json:"seriesId,omitempty"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	UpdatedAt   time.Time           `json:"updatedAt"`
+	StartTime   *time.Time          `json:"startTime,omitempty"`
+	EndTime     *time.Time          `json:"endTime,omitempty"`
+}
+
+// runList implements `seyal list`
+func runList(args []string) error {
+	fs := newFlagSet("list", `[--date YYYY-MM-DD | --from YYYY-MM-DD --to YYYY-MM-DD | --all] [filters] [--format tree|json|tsv]
+
+--due N lists the open tasks due within N days, overdue ones included, on
+every day unless a day or range is given.
+
+TSV columns: short ID, date, state, priority, depth, title, full ID.`)
+	date := fs.String("date", domain.Today().String(), "list a single day (YYYY-MM-DD)")
+	from := fs.String("from", "", "first day of a range (YYYY-MM-DD)")
+	to := fs.String("to", "", "last day of a range (YYYY-MM-DD)")
+	all := fs.Bool("all", false, "list every day")
+	state := fs.String("state", "", "only tasks in this state: todo, completed, delegated or delayed")
+	priority := fs.String("priority", "", "only tasks with this priority: 0 (none), 1, 2 or 3")
+	search := fs.String("search", "", "only tasks whose title contains this text (case-insensitive)")
+	tag := fs.String("tag", "", "only tasks with this tag, with or without the #")
+	due := fs.Int("due", 0, "only open tasks due within this many days, or overdue")
+	format := fs.String("format", "tree", "output format: tree, json or tsv")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError(fs, "unexpected argument %q", positional[0])
+	}
+
+	// Deadlines are looked for on every day by default
+	if flagPassed(fs, "due") && !flagPassed(fs, "date") && *from == "" && *to == "" {
+		*all = true
+	}
+	inRange, err := dateRange(fs, *date, *from, *to, *all)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	filter := listFilter{search: strings.ToLower(*search), tag: strings.TrimPrefix(*tag, "#")}
+	if *state != "" {
+		filter.state, err = domain.ParseTaskState(*state)
+		if err != nil {
+			return usageError(fs, "%v", err)
+		}
+	}
+	if flagPassed(fs, "due") {
+		if *due < 0 {
+			return usageError(fs, "--due must not be negative")
+		}
+		filter.dueBy = domain.Today().AddDays(*due).String()
+	}
+	if flagPassed(fs, "priority") {
+		filter.priority, err = parsePriority(*priority)
+		if err != nil {
+			return usageError(fs, "%v", err)
+		}
+		filter.hasPriority = true
+	}
+
+	switch *format {
+	case "tree", "json", "tsv":
+	default:
+		return usageError(fs, "invalid format %q (want tree, json or tsv)", *format)
+	}
+
+	store, err := storage.NewStorage()
+	if err != nil {
+		return err
+	}
+	schema, err := loadSchema(store)
+	if err != nil {
+		return err
+	}
+
+	ids := shortIDs(schema.Tasks)
+	days := make(map[string][]listedTask)
+	var dates []string
+	for _, d := range schema.Tasks.Dates() {
+		if !inRange(d) {
+			continue
+		}
+		if listed := selectTasks(schema.Tasks.GetTasksForDate(d), 0, filter); len(listed) > 0 {
+			days[d] = listed
+			dates = append(dates, d)
+		}
+	}
+
+	switch *format {
+	case "json":
+		return writeListJSON(dates, days, ids)
+	case "tsv":
+		writeListTSV(dates, days, ids)
+	default:
+		writeListTree(dates, days, ids)
+	}
+	return nil
+}
+
+// dateRange returns a predicate for the dates selected by the range flags
+func dateRange(fs *flag.FlagSet, date, from, to string, all bool) (func(string) bool, error) {
+	hasRange := from != "" || to != ""
+	if flagPassed(fs, "date") && (hasRange || all) {
+		return nil, fmt.Errorf("--date cannot be combined with --from, --to or --all")
+	}
+	if all && hasRange {
+		return nil, fmt.Errorf("--all cannot be combined with --from or --to")
+	}
+	if all {
+		return func(string) bool { return true }, nil
+	}
+
+	for _, d := range []string{date, from, to} {
+		if d == "" {
+			continue
+		}
+		if _, err := domain.ParseCalendarDate(d); err != nil {
+			return nil, err
+		}
+	}
+
+	if !hasRange {
+		return func(d string) bool { return d == date }, nil
+	}
+	if from != "" && to != "" && from > to {
+		return nil, fmt.Errorf("--from %s is after --to %s", from, to)
+	}
+	// YYYY-MM-DD strings sort chronologically
+	return func(d string) bool {
+		return (from == "" || d >= from) && (to == "" || d <= to)
+	}, nil
+}
+
+// selectTasks walks tasks and their children depth-first and keeps every
+// task that matches, plus the ancestors of matching children
+func selectTasks(tasks []*domain.Task, depth int, filter listFilter) []listedTask {
+	var result []listedTask
+	for _, task := range tasks {
+		children := selectTasks(task.Children, depth+1, filter)
+		matched := filter.matches(task)
+		if !matched && len(children) == 0 {
+			continue
+		}
+		result = append(result, listedTask{Task: task, Depth: depth, Matched: matched})
+		result = append(result, children...)
+	}
+	return result
+}
+
+func writeListTree(dates []string, days map[string][]listedTask, ids map[string]string) {
+	for i, d := range dates {
+		if i > 0 {
+			fmt.Fprintln(Stdout)
+		}
+		fmt.Fprintln(Stdout, d)
+		fmt.Fprintln(Stdout, "─────────────────────")
+		for _, lt := range days[d] {
+			task := lt.Task
+			line := strings.Repeat("  ", lt.Depth) + stateIcon(task.State) + " " + ids[task.ID] + " " + task.Title
+			if task.SeriesID != "" {
+				line += " ↻"
+			}
+			if len(task.Tags) > 0 {
+				line += " " + task.TagText()
+			}
+			if task.DueDate != "" {
+				line += " [due " + task.DueDate + "]"
+			}
+			if p := priorityLabel(task.Priority); p != "" {
+				line += " [" + p + "]"
+			}
+			if task.PushedCount > 0 {
+				line += fmt.Sprintf(" [↷%d]", task.PushedCount)
+			}
+			fmt.Fprintln(Stdout, line)
+		}
+	}
+}
+
+func writeListTSV(dates []string, days map[string][]listedTask, ids map[string]string) {
+	for _, d := range dates {
+		for _, lt := range days[d] {
+			if !lt.Matched {
+				continue
+			}
+			task := lt.Task
+			fmt.Fprintf(Stdout, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+				ids[task.ID], d, task.State, task.Priority, lt.Depth, tsvEscape(task.Title), task.ID)
+		}
+	}
+}
+
+func writeListJSON(dates []string, days map[string][]listedTask, ids map[string]string) error {
+	items := make([]listItem, 0)
+	for _, d := range dates {
+		for _, lt := range days[d] {
+			if !lt.Matched {
+				continue
+			}
+			task := lt.Task
+			items = append(items, listItem{
+				ID:          task.ID,
+				ShortID:     ids[task.ID],
+				Date:        d,
+				Title:       task.Title,
+				State:       task.State,
+				Priority:    task.Priority,
+				ParentID:    task.ParentID,
+				Depth:       lt.Depth,
+				PushedCount: task.PushedCount,
+				Tags:        task.Tags,
+				DueDate:     task.DueDate,
+				SeriesID:    task.SeriesID,
+				CreatedAt:   task.CreatedAt,
+				UpdatedAt:   task.UpdatedAt,
+				StartTime:   task.StartTime,
+				EndTime:     task.EndTime,
+			})
+		}
+	}
+
+	enc := json.NewEncoder(Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
+
+// tsvEscape keeps a field on one line and free of column separators
+func tsvEscape(s string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+}
+
+// stateIcon returns the same state icon used by the plain text export
+func stateIcon(state domain.TaskState) string {
+	switch state {
+	case domain.TaskStateCompleted:
+		return "●"
+	case domain.TaskStateDelegated:
+		return "→"
+	case domain.TaskStateDelayed:
+		return "‖"
+	default:
+		return "○"
+	}
+}
+
+// priorityLabel returns P1/P2/P3, or an empty string for no priority
+func priorityLabel(priority domain.TaskPriority) string {
+	switch priority {
+	case domain.PriorityHigh:
+		return "P1"
+	case domain.PriorityMed:
+		return "P2"
+	case domain.PriorityLow:
+		return "P3"
+	default:
+		return ""
+	}
+}
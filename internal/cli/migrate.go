@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/krisk248/seyal/internal/storage"
+)
+
+// runMigrate converts the data file to another storage backend
+func runMigrate(args []string) error {
+	fs := newFlagSet("migrate", "--to sqlite|json")
+	to := fs.String("to", "", "storage backend to convert to: sqlite or json")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError(fs, "unexpected argument %q", positional[0])
+	}
+	if *to == "" {
+		return usageError(fs, "--to is required")
+	}
+	kind, err := storage.ParseBackendKind(*to)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	store, err := storage.NewStorage()
+	if err != nil {
+		return err
+	}
+	converted, moved, err := store.Convert(kind)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, "Converted %s to %s\n", store.DataPath, converted.DataPath)
+	fmt.Fprintf(Stdout, "The previous file was kept as %s\n", moved)
+	return nil
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/krisk248/seyal/internal/server"
+	"github.com/krisk248/seyal/internal/storage"
+)
+
+// runServe implements `seyal serve`
+func runServe(args []string) error {
+	fs := newFlagSet("serve", `[--addr 127.0.0.1:PORT]
+
+Endpoints:
+  GET    /api/tasks?date=YYYY-MM-DD | ?from=YYYY-MM-DD&to=YYYY-MM-DD
+  POST   /api/tasks               {"title", "date", "priority", "parentId"}
+  GET    /api/tasks/{id}
+  PATCH  /api/tasks/{id}          {"title", "priority"}
+  DELETE /api/tasks/{id}
+  PUT    /api/tasks/{id}/state    {"state"}
+  POST   /api/tasks/{id}/start
+  POST   /api/tasks/{id}/stop
+  POST   /api/tasks/{id}/push
+  GET    /api/timeline?date=YYYY-MM-DD
+
+Requests must use a localhost Host, and writes need
+Content-Type: application/json.`)
+	addr := fs.String("addr", "127.0.0.1:7733", "address to listen on")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError(fs, "unexpected argument %q", positional[0])
+	}
+
+	store, err := storage.NewStorage()
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	if host, _, err := net.SplitHostPort(*addr); err == nil {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			fmt.Fprintf(Stderr, "warning: %s is reachable from other machines and the API has no authentication\n", *addr)
+		}
+	}
+
+	srv := &http.Server{
+		Handler:           server.New(store).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(Stdout, "Serving %s on http://%s\n", store.DataPath, ln.Addr())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
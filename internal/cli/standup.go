@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/atotto/clipboard"
+
+	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/storage"
+)
+
+// runStandup implements `seyal standup`
+func runStandup(args []string) error {
+	fs := newFlagSet("standup", `[--date YYYY-MM-DD] [--format markdown|text] [--copy]
+
+Lists what was completed and pushed on the previous working day (Friday on a
+Monday), the day's open tasks, and delegated or delayed tasks.`)
+	date := fs.String("date", domain.Today().String(), "day of the standup (YYYY-MM-DD)")
+	format := fs.String("format", "markdown", "output format: markdown or text")
+	copyOut := fs.Bool("copy", false, "copy the standup to the clipboard instead of printing it")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError(fs, "unexpected argument %q", positional[0])
+	}
+	day, err := domain.ParseCalendarDate(*date)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	render := storage.StandupMarkdown
+	switch *format {
+	case "markdown", "md":
+	case "text", "txt":
+		render = storage.StandupText
+	default:
+		return usageError(fs, "invalid format %q (want markdown or text)", *format)
+	}
+
+	store, err := storage.NewStorage()
+	if err != nil {
+		return err
+	}
+	schema, err := loadSchema(store)
+	if err != nil {
+		return err
+	}
+
+	report := render(domain.BuildStandup(schema.Tasks, schema.Timeline, day))
+	if *copyOut {
+		if err := clipboard.WriteAll(report); err != nil {
+			return fmt.Errorf("copying to the clipboard: %w", err)
+		}
+		fmt.Fprintln(Stderr, "Copied the standup to the clipboard")
+		return nil
+	}
+	fmt.Fprint(Stdout, report)
+	return nil
+}
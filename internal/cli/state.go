@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/ipc"
+	"github.com/krisk248/seyal/internal/storage"
+)
+
+// transition applies one state command to a task. It returns the message
+// printed on success and the equivalent request for a running TUI.
+type transition func(schema *storage.StorageSchema, task *domain.Task) (string, ipc.Request, error)
+
+// stateCommand builds a subcommand that applies fn to each task ID given
+func stateCommand(name string, fn transition) func(args []string) error {
+	return func(args []string) error {
+		fs := newFlagSet(name, "<id> [<id>...]")
+		ids, err := parseFlags(fs, args)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return usageError(fs, "no task ID given")
+		}
+
+		store, err := storage.NewStorage()
+		if err != nil {
+			return err
+		}
+
+		var tasks []*domain.Task
+		var messages []string
+		schema, err := update(store, func(schema *storage.StorageSchema) ([]ipc.Request, error) {
+			// Resolve every ID before changing anything
+			tasks = make([]*domain.Task, 0, len(ids))
+			for _, ref := range ids {
+				task, err := findTask(schema.Tasks, ref)
+				if err != nil {
+					return nil, err
+				}
+				tasks = append(tasks, task)
+			}
+
+			messages = make([]string, 0, len(tasks))
+			reqs := make([]ipc.Request, 0, len(tasks))
+			for _, task := range tasks {
+				msg, req, err := fn(schema, task)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", task.Title, err)
+				}
+				req.TaskID = task.ID
+				messages = append(messages, msg)
+				reqs = append(reqs, req)
+			}
+			return reqs, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		short := shortIDs(schema.Tasks)
+		for i, task := range tasks {
+			fmt.Fprintf(Stdout, "%s %s %s\n", messages[i], short[task.ID], task.Title)
+		}
+		return nil
+	}
+}
+
+// setState returns a transition that moves a task into state
+func setState(state domain.TaskState, verb string) transition {
+	return func(schema *storage.StorageSchema, task *domain.Task) (string, ipc.Request, error) {
+		if task.State == state {
+			return "", ipc.Request{}, fmt.Errorf("already %s", state)
+		}
+		domain.ChangeTaskState(schema.Timeline, task, state)
+		return verb, ipc.Request{Command: ipc.CommandState, State: string(state)}, nil
+	}
+}
+
+func startTask(schema *storage.StorageSchema, task *domain.Task) (string, ipc.Request, error) {
+	if task.IsRunning() {
+		return "", ipc.Request{}, fmt.Errorf("already running")
+	}
+	domain.StartTask(schema.Timeline, task)
+	return "Started", ipc.Request{Command: ipc.CommandStart}, nil
+}
+
+func stopTask(schema *storage.StorageSchema, task *domain.Task) (string, ipc.Request, error) {
+	if !task.IsRunning() {
+		return "", ipc.Request{}, fmt.Errorf("not running")
+	}
+	// The TUI logs no timeline event when a timer is stopped
+	task.Stop()
+	return "Stopped", ipc.Request{Command: ipc.CommandStop}, nil
+}
+
+func pushTask(schema *storage.StorageSchema, task *domain.Task) (string, ipc.Request, error) {
+	if _, err := domain.PushTask(schema.Tasks, schema.Timeline, task); err != nil {
+		return "", ipc.Request{}, err
+	}
+	return "Pushed to " + task.Date + ":", ipc.Request{Command: ipc.CommandPush}, nil
+}
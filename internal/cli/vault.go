@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/ipc"
+	"github.com/krisk248/seyal/internal/storage"
+)
+
+// runVault implements `seyal vault`
+func runVault(args []string) error {
+	fs := newFlagSet("vault", `[--set DIR | --off]
+
+Keeps a section of each YYYY-MM-DD.md daily note in DIR in sync with the
+day's tasks. Without flags, reads the checkboxes changed in the notes into
+the tasks, then rewrites the sections. Notes are never created. A note
+edited where seyal changed the same task is reported and left alone.`)
+	set := fs.String("set", "", "folder of the daily notes to sync with, then sync")
+	off := fs.Bool("off", false, "stop syncing with the vault")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError(fs, "unexpected argument %q", positional[0])
+	}
+	if *off && *set != "" {
+		return usageError(fs, "--set and --off cannot be combined")
+	}
+
+	store, err := storage.NewStorage()
+	if err != nil {
+		return err
+	}
+
+	if *off {
+		if err := setVault(store, ""); err != nil {
+			return err
+		}
+		fmt.Fprintln(Stdout, "Stopped syncing with the vault")
+		return nil
+	}
+	if *set != "" {
+		dir, err := filepath.Abs(*set)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(dir); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return usageError(fs, "%s is not a folder", dir)
+		}
+		if err := setVault(store, dir); err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, "Syncing with the daily notes in %s\n", dir)
+	}
+
+	var vault *storage.Vault
+	var report *storage.VaultReport
+	schema, err := update(store, func(schema *storage.StorageSchema) ([]ipc.Request, error) {
+		if schema.Settings.Vault == "" {
+			return nil, fmt.Errorf("no vault set, see 'seyal vault --set DIR'")
+		}
+		vault = store.Vault(schema.Settings.Vault)
+		var err error
+		if report, err = vault.Read(schema.Tasks); err != nil {
+			return nil, err
+		}
+
+		var reqs []ipc.Request
+		for _, change := range report.Changes {
+			if task := schema.Tasks.FindTask(change.TaskID); task != nil && task.State != change.State {
+				reqs = append(reqs, ipc.Request{Command: ipc.CommandState, TaskID: task.ID, State: string(change.State)})
+			}
+		}
+		report.Apply(schema.Tasks, schema.Timeline)
+		return reqs, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	short := shortIDs(schema.Tasks)
+	for _, change := range report.Changes {
+		fmt.Fprintf(Stdout, "%s %s %s (%s.md)\n", stateVerb(change.State), short[change.TaskID], change.Title, change.Date)
+	}
+	conflicts, err := vault.Write(schema.Tasks)
+	if err != nil {
+		return err
+	}
+	for _, conflict := range conflicts {
+		fmt.Fprintf(Stderr, "Conflict: %s\n", conflict)
+	}
+	switch len(conflicts) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("1 conflict; the note was left as it is")
+	default:
+		return fmt.Errorf("%d conflicts; those notes were left as they are", len(conflicts))
+	}
+}
+
+// setVault changes the vault setting. Settings cannot be forwarded to a
+// running TUI, so this needs the data file lock.
+func setVault(store *storage.Storage, dir string) error {
+	return store.Update(func(schema *storage.StorageSchema) error {
+		schema.Settings.Vault = dir
+		return nil
+	})
+}
+
+// stateVerb describes moving a task into state, as the state commands do
+func stateVerb(state domain.TaskState) string {
+	switch state {
+	case domain.TaskStateCompleted:
+		return "Completed"
+	case domain.TaskStateDelegated:
+		return "Delegated"
+	case domain.TaskStateDelayed:
+		return "Delayed"
+	default:
+		return "Reopened"
+	}
+}
@@ -0,0 +1,66 @@
+package domain
+
+import "time"
+
+// The functions in this file apply a change to a task and record the
+// timeline event that goes with it. The TUI and the headless commands both
+// go through them so the timeline stays the same no matter where a change
+// was made. Events are logged on the day the task belongs to.
+
+// AddNewTask adds a task (as a child when parent is non-nil) and logs its creation
+func AddNewTask(tt TaskTree, tl Timeline, task *Task, parent *Task) *TimelineEvent {
+	if parent != nil {
+		parent.AddChild(task)
+	} else {
+		tt.AddTask(task)
+	}
+	event := NewTimelineEvent(task.ID, task.Title, EventCreated)
+	tl.AddEvent(task.Date, event)
+	return event
+}
+
+// ChangeTaskState sets a task's state and logs the change when it is
+// meaningful. It returns nil when no event was logged.
+func ChangeTaskState(tl Timeline, task *Task, newState TaskState) *TimelineEvent {
+	prevState := task.State
+	task.SetState(newState)
+	event := NewStateChangeEvent(task.ID, task.Title, prevState, newState)
+	if event != nil {
+		tl.AddEvent(task.Date, event)
+	}
+	return event
+}
+
+// StartTask starts a task's timer and logs a started event
+func StartTask(tl Timeline, task *Task) *TimelineEvent {
+	task.Start()
+	event := NewTimelineEvent(task.ID, task.Title, EventStarted)
+	tl.AddEvent(task.Date, event)
+	return event
+}
+
+// PushTask moves a task to the day after its current date, bumps its pushed
+// count and logs a pushed event on the day it was moved from
+func PushTask(tt TaskTree, tl Timeline, task *Task) (*TimelineEvent, error) {
+	current, err := ParseCalendarDate(task.Date)
+	if err != nil {
+		return nil, err
+	}
+	currentDate := task.Date
+	nextDate := current.AddDays(1).String()
+
+	// Increment pushed count
+	task.PushedCount++
+	task.UpdatedAt = time.Now()
+
+	// Remove from current day
+	tt.RemoveTask(currentDate, task.ID)
+
+	// Update task date and add to next day
+	task.Date = nextDate
+	tt.AddTask(task)
+
+	event := NewTimelineEvent(task.ID, task.Title, EventPushed)
+	tl.AddEvent(currentDate, event)
+	return event, nil
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -136,3 +137,97 @@ func GenerateCalendarGrid(date CalendarDate) CalendarGrid {
 func (g CalendarGrid) IsCurrentMonth(date CalendarDate) bool {
 	return date.Month == g.Month && date.Year == g.Year
 }
+
+// ParseCalendarDate parses a YYYY-MM-DD string into a CalendarDate
+func ParseCalendarDate(s string) (CalendarDate, error) {
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return CalendarDate{}, fmt.Errorf("invalid date %q (want YYYY-MM-DD)", s)
+	}
+	return NewCalendarDate(t), nil
+}
+
+// StartOfWeek returns the Monday of the ISO week containing the date
+func (d CalendarDate) StartOfWeek() CalendarDate {
+	offset := (int(d.Weekday()) + 6) % 7
+	return d.AddDays(-offset)
+}
+
+// LastDayOfMonth returns the CalendarDate for the last day of the month
+func (d CalendarDate) LastDayOfMonth() CalendarDate {
+	return CalendarDate{Year: d.Year, Month: d.Month, Day: d.DaysInMonth()}
+}
+
+// DateRange is an inclusive range of YYYY-MM-DD days. An empty From or To
+// leaves that end open, so the zero value covers every day.
+type DateRange struct {
+	From string
+	To   string
+}
+
+// SingleDay returns the range covering only date
+func SingleDay(date string) DateRange {
+	return DateRange{From: date, To: date}
+}
+
+// Contains reports whether date falls in the range
+func (r DateRange) Contains(date string) bool {
+	// YYYY-MM-DD strings sort chronologically
+	return (r.From == "" || date >= r.From) && (r.To == "" || date <= r.To)
+}
+
+// IsAll reports whether the range covers every day
+func (r DateRange) IsAll() bool {
+	return r.From == "" && r.To == ""
+}
+
+func (r DateRange) String() string {
+	switch {
+	case r.IsAll():
+		return "all"
+	case r.From == r.To:
+		return r.From
+	default:
+		return r.From + ".." + r.To
+	}
+}
+
+// FileName names the range in export file names, as in
+// "2026-01-01_to_2026-01-31", "from-2026-01-01" or "until-2026-01-31"
+func (r DateRange) FileName() string {
+	switch {
+	case r.IsAll() || r.From == r.To:
+		return r.String()
+	case r.To == "":
+		return "from-" + r.From
+	case r.From == "":
+		return "until-" + r.To
+	default:
+		return r.From + "_to_" + r.To
+	}
+}
+
+// ParseDateRange parses "FROM..TO", where either end may be left out, or a
+// single YYYY-MM-DD day
+func ParseDateRange(s string) (DateRange, error) {
+	from, to, found := strings.Cut(strings.TrimSpace(s), "..")
+	if !found {
+		to = from
+	}
+	r := DateRange{From: strings.TrimSpace(from), To: strings.TrimSpace(to)}
+	for _, d := range []string{r.From, r.To} {
+		if d == "" {
+			continue
+		}
+		if _, err := ParseCalendarDate(d); err != nil {
+			return DateRange{}, err
+		}
+	}
+	if !found && r.From == "" {
+		return DateRange{}, fmt.Errorf("empty date range")
+	}
+	if r.From != "" && r.To != "" && r.From > r.To {
+		return DateRange{}, fmt.Errorf("range starts %s, after it ends %s", r.From, r.To)
+	}
+	return r, nil
+}
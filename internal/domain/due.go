@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DaysUntilDue counts the days from today to the task's due date: 0 when
+// it is due today, negative once it is overdue. ok is false when the task
+// has no due date.
+func (t *Task) DaysUntilDue(today CalendarDate) (days int, ok bool) {
+	due, err := ParseCalendarDate(t.DueDate)
+	if t.DueDate == "" || err != nil {
+		return 0, false
+	}
+	return civilDay(due.Time()) - civilDay(today.Time()), true
+}
+
+// IsOverdue reports whether the task is still open after its due date
+func (t *Task) IsOverdue(today CalendarDate) bool {
+	days, ok := t.DaysUntilDue(today)
+	return ok && days < 0 && t.State != TaskStateCompleted
+}
+
+// DueText describes how long is left until the task is due, such as
+// "due tomorrow", "3d left" or "2d overdue". It is empty without a due
+// date and once the task is completed.
+func (t *Task) DueText(today CalendarDate) string {
+	days, ok := t.DaysUntilDue(today)
+	if !ok || t.State == TaskStateCompleted {
+		return ""
+	}
+	switch {
+	case days < 0:
+		return fmt.Sprintf("%dd overdue", -days)
+	case days == 0:
+		return "due today"
+	case days == 1:
+		return "due tomorrow"
+	default:
+		return fmt.Sprintf("%dd left", days)
+	}
+}
+
+// ParseDueDate reads a due date as typed: YYYY-MM-DD, "today",
+// "tomorrow", a weekday name for the next such day, or a distance such as
+// "+3", "3d" or "2w". It returns the day as YYYY-MM-DD.
+func ParseDueDate(text string, today CalendarDate) (string, error) {
+	w := strings.ToLower(strings.TrimSpace(text))
+	switch w {
+	case "today":
+		return today.String(), nil
+	case "tomorrow":
+		return today.AddDays(1).String(), nil
+	}
+	if day := ruleWeekday(w); day >= 0 {
+		ahead := (int(day)-int(today.Weekday())+6)%7 + 1
+		return today.AddDays(ahead).String(), nil
+	}
+
+	n, unit := strings.TrimPrefix(w, "+"), 1
+	switch {
+	case strings.HasSuffix(n, "w"):
+		n, unit = strings.TrimSuffix(n, "w"), 7
+	case strings.HasSuffix(n, "d"):
+		n = strings.TrimSuffix(n, "d")
+	}
+	if days, err := strconv.Atoi(n); err == nil && !strings.ContainsAny(n, "+-") {
+		return today.AddDays(days * unit).String(), nil
+	}
+
+	date, err := ParseCalendarDate(w)
+	if err != nil {
+		return "", fmt.Errorf("invalid due date %q (want YYYY-MM-DD, today, tomorrow, a weekday or +N days)", text)
+	}
+	return date.String(), nil
+}
+
+// DueWithin returns the open tasks, subtasks included, that are due
+// within days of today or are already overdue, soonest first
+func (tt TaskTree) DueWithin(today CalendarDate, days int) []*Task {
+	last := today.AddDays(days).String()
+	var due []*Task
+	for _, tasks := range tt {
+		for _, ft := range FlattenTasks(tasks, 0, false) {
+			task := ft.Task
+			if task.DueDate != "" && task.DueDate <= last && task.State != TaskStateCompleted {
+				due = append(due, task)
+			}
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].DueDate != due[j].DueDate {
+			return due[i].DueDate < due[j].DueDate
+		}
+		if due[i].Date != due[j].Date {
+			return due[i].Date < due[j].Date
+		}
+		return due[i].Title < due[j].Title
+	})
+	return due
+}
+
+// DueDays counts the open tasks due on each day
+func (tt TaskTree) DueDays() map[string]int {
+	counts := make(map[string]int)
+	for _, tasks := range tt {
+		for _, ft := range FlattenTasks(tasks, 0, false) {
+			if ft.Task.DueDate != "" && ft.Task.State != TaskStateCompleted {
+				counts[ft.Task.DueDate]++
+			}
+		}
+	}
+	return counts
+}
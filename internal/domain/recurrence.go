@@ -0,0 +1,930 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecurrenceLookahead is how many days past today the occurrences of
+// repeating tasks are made ahead of time, so the coming weeks show them
+const RecurrenceLookahead = 56
+
+// RecurrenceHorizon is the last day occurrences are made for when data is
+// loaded
+func RecurrenceHorizon() string {
+	return Today().AddDays(RecurrenceLookahead).String()
+}
+
+// Frequency is how often a rule repeats, as in an RRULE's FREQ
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// RuleDay is a BYDAY entry: a weekday, and with N set the Nth of those in
+// the month, counted from the end when negative, as in "2TU" or "-1FR"
+type RuleDay struct {
+	N   int
+	Day time.Weekday
+}
+
+// Rule is the part of an RFC 5545 RRULE seyal repeats tasks by: FREQ
+// (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL, BYDAY, BYMONTHDAY,
+// BYMONTH, COUNT and UNTIL. Weeks start on Monday. Without BYDAY or
+// BYMONTHDAY the day comes from the series' first occurrence.
+type Rule struct {
+	Freq       Frequency
+	Interval   int // 0 or 1 for every day, week, month or year
+	ByDay      []RuleDay
+	ByMonthDay []int // Negative counts from the end of the month
+	ByMonth    []time.Month
+	Count      int    // Occurrences in all, 0 for no limit
+	Until      string // Last day it may repeat on, YYYY-MM-DD
+}
+
+var (
+	ruleDayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+	ruleDayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+	workWeek     = []RuleDay{{Day: time.Monday}, {Day: time.Tuesday}, {Day: time.Wednesday}, {Day: time.Thursday}, {Day: time.Friday}}
+)
+
+// ParseRRule parses RRULE text such as "FREQ=MONTHLY;BYDAY=2TU", with or
+// without the "RRULE:" in front
+func ParseRRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+
+	var r Rule
+	for _, part := range strings.Split(s, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("invalid RRULE part %q", part)
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = Frequency(value)
+		case "INTERVAL":
+			r.Interval, err = ruleInt(key, value)
+		case "COUNT":
+			r.Count, err = ruleInt(key, value)
+		case "UNTIL":
+			// A date-time UNTIL ends on its day
+			if len(value) < 8 {
+				return Rule{}, fmt.Errorf("invalid UNTIL %q", value)
+			}
+			var day CalendarDate
+			if day, err = ParseCalendarDate(value[:4] + "-" + value[4:6] + "-" + value[6:8]); err == nil {
+				r.Until = day.String()
+			}
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				day, err := parseRuleDay(v)
+				if err != nil {
+					return Rule{}, err
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				n, err := ruleInt(key, v)
+				if err != nil {
+					return Rule{}, err
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				n, err := ruleInt(key, v)
+				if err != nil {
+					return Rule{}, err
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			if value != "MO" {
+				err = fmt.Errorf("only WKST=MO is supported")
+			}
+		default:
+			return Rule{}, fmt.Errorf("RRULE %s is not supported", key)
+		}
+		if err != nil {
+			return Rule{}, err
+		}
+	}
+	return r, r.validate()
+}
+
+func ruleInt(key, value string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return n, nil
+}
+
+// parseRuleDay parses a BYDAY entry such as "MO", "2TU" or "-1FR"
+func parseRuleDay(s string) (RuleDay, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return RuleDay{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+	day := slices.Index(ruleDayCodes, s[len(s)-2:])
+	if day < 0 {
+		return RuleDay{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+	var n int
+	if prefix := s[:len(s)-2]; prefix != "" {
+		var err error
+		if n, err = strconv.Atoi(strings.TrimPrefix(prefix, "+")); err != nil || n == 0 {
+			return RuleDay{}, fmt.Errorf("invalid BYDAY %q", s)
+		}
+	}
+	return RuleDay{N: n, Day: time.Weekday(day)}, nil
+}
+
+// validate checks the parts of a rule fit together
+func (r Rule) validate() error {
+	switch r.Freq {
+	case Daily, Weekly, Monthly, Yearly:
+	case "":
+		return fmt.Errorf("RRULE needs a FREQ")
+	default:
+		return fmt.Errorf("FREQ=%s is not supported (want DAILY, WEEKLY, MONTHLY or YEARLY)", r.Freq)
+	}
+	if r.Interval < 0 || r.Count < 0 {
+		return fmt.Errorf("INTERVAL and COUNT cannot be negative")
+	}
+	if r.Count > 0 && r.Until != "" {
+		return fmt.Errorf("COUNT and UNTIL cannot be combined")
+	}
+	for _, d := range r.ByDay {
+		if d.N == 0 {
+			continue
+		}
+		if r.Freq != Monthly && (r.Freq != Yearly || len(r.ByMonth) == 0) {
+			return fmt.Errorf("%s needs a monthly rule, or a yearly one with BYMONTH", d)
+		}
+		if d.N < -5 || d.N > 5 {
+			return fmt.Errorf("a month has at most 5 of a weekday, not %d", d.N)
+		}
+	}
+	for _, n := range r.ByMonthDay {
+		if n == 0 || n < -31 || n > 31 {
+			return fmt.Errorf("invalid day of the month %d", n)
+		}
+		if r.Freq == Weekly {
+			return fmt.Errorf("a weekly rule cannot have days of the month")
+		}
+	}
+	for _, m := range r.ByMonth {
+		if m < time.January || m > time.December {
+			return fmt.Errorf("invalid month %d", m)
+		}
+	}
+	return nil
+}
+
+func (d RuleDay) String() string {
+	if d.N == 0 {
+		return ruleDayCodes[d.Day]
+	}
+	return strconv.Itoa(d.N) + ruleDayCodes[d.Day]
+}
+
+// String writes the rule as RRULE text, without "RRULE:"
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = d.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, n := range r.ByMonthDay {
+			days[i] = strconv.Itoa(n)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			months[i] = strconv.Itoa(int(m))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if r.Until != "" {
+		parts = append(parts, "UNTIL="+strings.ReplaceAll(r.Until, "-", ""))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// MarshalText stores a rule as its RRULE text
+func (r Rule) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText reads the RRULE text MarshalText writes
+func (r *Rule) UnmarshalText(text []byte) error {
+	rule, err := ParseRRule(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rule
+	return nil
+}
+
+// ruleFillers are words of a typed rule that only read well
+var ruleFillers = map[string]bool{
+	"on": true, "the": true, "of": true, "and": true, "in": true, "day": true, "days": true,
+}
+
+// ruleOrdinals are the words for the Nth weekday or day of a month
+var ruleOrdinals = map[string]int{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5,
+}
+
+// ParseRule parses a repeat rule as typed: an RRULE such as
+// "FREQ=WEEKLY;BYDAY=MO,FR", or a phrase such as "daily", "weekdays",
+// "weekly on mon, thu", "every 2 weeks on fri", "monthly on the 15th",
+// "monthly on the 2nd tue", "monthly on the last day" or "yearly", which
+// may end in "until YYYY-MM-DD" or "N times"
+func ParseRule(text string) (Rule, error) {
+	if strings.Contains(strings.ToUpper(text), "FREQ=") {
+		return ParseRRule(text)
+	}
+	words := strings.Fields(strings.ToLower(strings.NewReplacer(",", " ", ";", " ").Replace(text)))
+	if len(words) == 0 {
+		return Rule{}, fmt.Errorf("empty repeat rule")
+	}
+
+	var r Rule
+	i := 1
+	switch words[0] {
+	case "daily":
+		r.Freq = Daily
+	case "weekly":
+		r.Freq = Weekly
+	case "monthly":
+		r.Freq = Monthly
+	case "yearly", "annually":
+		r.Freq = Yearly
+	case "weekdays":
+		r.Freq, r.ByDay = Weekly, slices.Clone(workWeek)
+	case "every", "each":
+		if i < len(words) && words[i] == "other" {
+			r.Interval = 2
+			i++
+		} else if i < len(words) {
+			if n, err := strconv.Atoi(words[i]); err == nil {
+				r.Interval = n
+				i++
+			}
+		}
+		if i < len(words) {
+			switch strings.TrimSuffix(words[i], "s") {
+			case "day":
+				r.Freq = Daily
+			case "week":
+				r.Freq = Weekly
+			case "month":
+				r.Freq = Monthly
+			case "year":
+				r.Freq = Yearly
+			case "weekday":
+				r.Freq, r.ByDay = Weekly, slices.Clone(workWeek)
+			}
+			if r.Freq != "" {
+				i++
+			}
+		}
+		if r.Freq == "" {
+			if r.Interval > 0 {
+				return Rule{}, fmt.Errorf("every %d what? Say days, weeks, months or years", r.Interval)
+			}
+			// "every mon, thu"
+			r.Freq = Weekly
+		}
+	default:
+		return Rule{}, fmt.Errorf("unknown repeat rule %q (try daily, weekdays, weekly on mon, monthly on the 15th or an RRULE)", text)
+	}
+
+	// A number waits for the word after it: "2nd tue" is a weekday of the
+	// month, "15th" alone a day of the month
+	pending := 0
+	flush := func() {
+		if pending != 0 {
+			r.ByMonthDay = append(r.ByMonthDay, pending)
+			pending = 0
+		}
+	}
+	for ; i < len(words); i++ {
+		w := words[i]
+		if ruleFillers[w] {
+			continue
+		}
+		if day := ruleWeekday(w); day >= 0 {
+			r.ByDay = append(r.ByDay, RuleDay{N: pending, Day: day})
+			pending = 0
+			continue
+		}
+		if month := ruleMonth(w); month > 0 {
+			flush()
+			r.ByMonth = append(r.ByMonth, month)
+			continue
+		}
+		switch {
+		case w == "weekday" || w == "weekdays":
+			flush()
+			r.ByDay = append(r.ByDay, workWeek...)
+		case w == "until":
+			flush()
+			if i+1 >= len(words) {
+				return Rule{}, fmt.Errorf("until needs a YYYY-MM-DD day")
+			}
+			i++
+			day, err := ParseCalendarDate(words[i])
+			if err != nil {
+				return Rule{}, err
+			}
+			r.Until = day.String()
+		case w == "last":
+			// "2nd last" counts from the end
+			if pending > 0 {
+				pending = -pending
+			} else {
+				flush()
+				pending = -1
+			}
+		default:
+			n, ok := ruleNumber(w)
+			if !ok {
+				return Rule{}, fmt.Errorf("unknown word %q in repeat rule", w)
+			}
+			if i+1 < len(words) && (words[i+1] == "times" || words[i+1] == "time") {
+				r.Count = n
+				i++
+				continue
+			}
+			flush()
+			pending = n
+		}
+	}
+	flush()
+	return r, r.validate()
+}
+
+// ruleWeekday returns the weekday a word names, or -1
+func ruleWeekday(w string) time.Weekday {
+	if len(w) < 3 {
+		return -1
+	}
+	for day, name := range ruleDayNames {
+		if strings.HasPrefix(w, name) && strings.HasPrefix(strings.ToLower(time.Weekday(day).String()), strings.TrimSuffix(w, "s")) {
+			return time.Weekday(day)
+		}
+	}
+	return -1
+}
+
+// ruleMonth returns the month a word names, or 0
+func ruleMonth(w string) time.Month {
+	if len(w) < 3 {
+		return 0
+	}
+	for m := time.January; m <= time.December; m++ {
+		if strings.HasPrefix(strings.ToLower(m.String()), w) {
+			return m
+		}
+	}
+	return 0
+}
+
+// ruleNumber reads "2", "2nd" or "second"
+func ruleNumber(w string) (int, bool) {
+	if n, ok := ruleOrdinals[w]; ok {
+		return n, true
+	}
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		w = strings.TrimSuffix(w, suffix)
+	}
+	n, err := strconv.Atoi(w)
+	return n, err == nil && n > 0
+}
+
+// Describe writes the rule as a phrase ParseRule reads back, such as
+// "every 2 weeks on mon, fri"
+func (r Rule) Describe() string {
+	var b strings.Builder
+	byDay := r.ByDay
+	n := max(1, r.Interval)
+	switch {
+	case r.Freq == Weekly && n == 1 && slices.Equal(r.ByDay, workWeek):
+		b.WriteString("weekdays")
+		byDay = nil
+	case n == 1:
+		b.WriteString(strings.ToLower(string(r.Freq)))
+	default:
+		unit := map[Frequency]string{Daily: "days", Weekly: "weeks", Monthly: "months", Yearly: "years"}[r.Freq]
+		fmt.Fprintf(&b, "every %d %s", n, unit)
+	}
+
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			months[i] = strings.ToLower(m.String()[:3])
+		}
+		b.WriteString(" in " + strings.Join(months, ", "))
+	}
+	// Weekdays go first: in "13th fri" the number would count Fridays
+	var on []string
+	numbered := len(r.ByMonthDay) > 0
+	for _, d := range byDay {
+		if d.N == 0 {
+			on = append(on, ruleDayNames[d.Day])
+		} else {
+			on = append(on, ruleOrdinal(d.N)+" "+ruleDayNames[d.Day])
+			numbered = true
+		}
+	}
+	for _, d := range r.ByMonthDay {
+		if d < 0 {
+			on = append(on, ruleOrdinal(d)+" day")
+		} else {
+			on = append(on, ruleOrdinal(d))
+		}
+	}
+	if numbered {
+		b.WriteString(" on the " + strings.Join(on, ", "))
+	} else if len(on) > 0 {
+		b.WriteString(" on " + strings.Join(on, ", "))
+	}
+
+	if r.Until != "" {
+		b.WriteString(" until " + r.Until)
+	}
+	if r.Count > 0 {
+		fmt.Fprintf(&b, " %d times", r.Count)
+	}
+	return b.String()
+}
+
+// ruleOrdinal writes 2 as "2nd", -1 as "last" and -2 as "2nd last"
+func ruleOrdinal(n int) string {
+	if n == -1 {
+		return "last"
+	}
+	if n < 0 {
+		return ruleOrdinal(-n) + " last"
+	}
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// withStart spells out the day a rule takes from the series' first
+// occurrence, so Describe says "weekly on fri" rather than "weekly"
+func (r Rule) withStart(start CalendarDate) Rule {
+	switch {
+	case len(r.ByDay) > 0 || len(r.ByMonthDay) > 0:
+	case r.Freq == Weekly:
+		r.ByDay = []RuleDay{{Day: start.Weekday()}}
+	case r.Freq == Monthly:
+		r.ByMonthDay = []int{start.Day}
+	case r.Freq == Yearly:
+		if len(r.ByMonth) == 0 {
+			r.ByMonth = []time.Month{start.Month}
+		}
+		r.ByMonthDay = []int{start.Day}
+	}
+	return r
+}
+
+// Dates returns the days after after, up to and including through, that a
+// series starting on start repeats on. start is always the first
+// occurrence, and counts towards COUNT.
+func (r Rule) Dates(start CalendarDate, after, through string) []string {
+	if r.Until != "" && r.Until < through {
+		through = r.Until
+	}
+	first := start.Time()
+	t := first.AddDate(0, 0, 1)
+	// Only COUNT needs the days before after
+	if r.Count == 0 && after > start.String() {
+		if from, err := ParseCalendarDate(after); err == nil {
+			t = from.Time().AddDate(0, 0, 1)
+		}
+	}
+
+	var dates []string
+	count := 1
+	for ; ; t = t.AddDate(0, 0, 1) {
+		day := NewCalendarDate(t).String()
+		if day > through || (r.Count > 0 && count >= r.Count) {
+			break
+		}
+		if !r.matches(first, t) {
+			continue
+		}
+		count++
+		if day > after {
+			dates = append(dates, day)
+		}
+	}
+	return dates
+}
+
+// matches reports whether day, after start, fits the rule apart from COUNT
+// and UNTIL
+func (r Rule) matches(start, day time.Time) bool {
+	n := max(1, r.Interval)
+	switch r.Freq {
+	case Daily:
+		if (civilDay(day)-civilDay(start))%n != 0 {
+			return false
+		}
+	case Weekly:
+		if (mondayOf(day)-mondayOf(start))/7%n != 0 {
+			return false
+		}
+	case Monthly:
+		months := (day.Year()-start.Year())*12 + int(day.Month()) - int(start.Month())
+		if months%n != 0 {
+			return false
+		}
+	case Yearly:
+		if (day.Year()-start.Year())%n != 0 {
+			return false
+		}
+	}
+
+	if len(r.ByMonth) > 0 && !slices.Contains(r.ByMonth, day.Month()) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !r.onMonthDay(day) {
+		return false
+	}
+	switch {
+	case len(r.ByDay) > 0:
+		return r.onWeekday(day)
+	case len(r.ByMonthDay) > 0:
+		return true
+	case r.Freq == Weekly:
+		return day.Weekday() == start.Weekday()
+	case r.Freq == Monthly:
+		return day.Day() == start.Day()
+	case r.Freq == Yearly:
+		return day.Day() == start.Day() && (len(r.ByMonth) > 0 || day.Month() == start.Month())
+	}
+	return true
+}
+
+func (r Rule) onMonthDay(day time.Time) bool {
+	last := NewCalendarDate(day).DaysInMonth()
+	for _, n := range r.ByMonthDay {
+		if n == day.Day() || (n < 0 && last+n+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) onWeekday(day time.Time) bool {
+	last := NewCalendarDate(day).DaysInMonth()
+	for _, d := range r.ByDay {
+		switch {
+		case d.Day != day.Weekday():
+		case d.N == 0:
+			return true
+		case d.N > 0 && (day.Day()-1)/7+1 == d.N:
+			return true
+		case d.N < 0 && (last-day.Day())/7+1 == -d.N:
+			return true
+		}
+	}
+	return false
+}
+
+// civilDay numbers days, ignoring time zones and daylight saving
+func civilDay(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// mondayOf returns the civilDay of the Monday starting t's week
+func mondayOf(t time.Time) int {
+	return civilDay(t) - (int(t.Weekday())+6)%7
+}
+
+// Recurrence is a repeating task. Each occurrence is an ordinary task on
+// its day, copied from Template when the series is first expanded over
+// that day, so occurrences are completed, pushed, edited and deleted on
+// their own.
+type Recurrence struct {
+	ID       string `json:"id"`
+	Rule     Rule   `json:"rule"`     // Stored as RRULE text
+	Start    string `json:"start"`    // Day of the first occurrence, YYYY-MM-DD
+	Through  string `json:"through"`  // Occurrences were made up to this day
+	Template *Task  `json:"template"` // Title, priority, tags and subtasks of new occurrences
+}
+
+// Recurrences are the repeating tasks of a data file
+type Recurrences []*Recurrence
+
+// NewRecurrence makes task the first occurrence of a series repeating by
+// rule. IDs are derived from the task's, so a headless command and the
+// TUI it forwards to make the same series and occurrences.
+func NewRecurrence(task *Task, rule Rule) *Recurrence {
+	start := task.Occurrence
+	if start == "" {
+		start = task.Date
+	}
+	r := &Recurrence{
+		ID:      derivedID("recurrence", task.ID),
+		Rule:    rule,
+		Start:   start,
+		Through: firstThrough(start),
+	}
+	r.Template = templateOf(task, r.ID)
+	task.SeriesID = r.ID
+	task.Occurrence = start
+	return r
+}
+
+// firstThrough is where a series starting on start begins filling in
+// days: days already gone are left empty
+func firstThrough(start string) string {
+	if yesterday := Today().AddDays(-1).String(); yesterday > start {
+		return yesterday
+	}
+	return start
+}
+
+// derivedID makes a task or series ID from a name that is unique to it
+func derivedID(kind, name string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("seyal:"+kind+":"+name)).String()
+}
+
+// templateOf copies what occurrences share from task: its title,
+// priority, tags and subtasks
+func templateOf(task *Task, id string) *Task {
+	template := &Task{
+		ID:       id,
+		Title:    task.Title,
+		State:    TaskStateTodo,
+		Priority: task.Priority,
+		Tags:     slices.Clone(task.Tags),
+		Children: make([]*Task, 0, len(task.Children)),
+	}
+	for _, child := range task.Children {
+		template.Children = append(template.Children, templateOf(child, derivedID("template", id+"/"+child.ID)))
+	}
+	return template
+}
+
+// Describe writes the series' rule as a phrase, with the day it repeats on
+// spelled out
+func (r *Recurrence) Describe() string {
+	start, err := ParseCalendarDate(r.Start)
+	if err != nil {
+		return r.Rule.Describe()
+	}
+	return r.Rule.withStart(start).Describe()
+}
+
+// Expand makes the occurrences due after Through, up to and including
+// through, and moves Through on. Days are only filled once, so a deleted
+// occurrence stays deleted. It returns the new occurrences.
+func (r *Recurrence) Expand(tt TaskTree, through string) []*Task {
+	if through <= r.Through || r.Template == nil {
+		return nil
+	}
+	start, err := ParseCalendarDate(r.Start)
+	if err != nil {
+		return nil
+	}
+
+	var added []*Task
+	for _, day := range r.Rule.Dates(start, r.Through, through) {
+		task := r.occurrence(day)
+		// A series stopped and started again from the same task has the
+		// same IDs; keep what is left of the first one
+		if tt.FindTask(task.ID) != nil {
+			continue
+		}
+		tt.AddTask(task)
+		added = append(added, task)
+	}
+	r.Through = through
+	return added
+}
+
+// Upcoming returns the occurrences Expand would make up to and including
+// through, without adding them to tt or moving Through on, so days past
+// the lookahead can show them
+func (r *Recurrence) Upcoming(tt TaskTree, through string) []*Task {
+	if through <= r.Through || r.Template == nil {
+		return nil
+	}
+	start, err := ParseCalendarDate(r.Start)
+	if err != nil {
+		return nil
+	}
+
+	var upcoming []*Task
+	for _, day := range r.Rule.Dates(start, r.Through, through) {
+		if task := r.occurrence(day); tt.FindTask(task.ID) == nil {
+			upcoming = append(upcoming, task)
+		}
+	}
+	return upcoming
+}
+
+// occurrence makes the series' task for day from the template
+func (r *Recurrence) occurrence(day string) *Task {
+	task := instantiate(r.Template, day, "", time.Now())
+	task.SeriesID = r.ID
+	task.Occurrence = day
+	return task
+}
+
+func instantiate(template *Task, day, parentID string, now time.Time) *Task {
+	task := &Task{
+		ID:        derivedID("occurrence", template.ID+"/"+day),
+		Title:     template.Title,
+		State:     TaskStateTodo,
+		Priority:  template.Priority,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ParentID:  parentID,
+		Date:      day,
+		Tags:      slices.Clone(template.Tags),
+		Children:  make([]*Task, 0, len(template.Children)),
+		Expanded:  true,
+	}
+	for _, child := range template.Children {
+		task.Children = append(task.Children, instantiate(child, day, task.ID, now))
+	}
+	return task
+}
+
+// occurrences returns the series' tasks in tt
+func (r *Recurrence) occurrences(tt TaskTree) []*Task {
+	var result []*Task
+	for _, tasks := range tt {
+		for _, task := range tasks {
+			if task.SeriesID == r.ID {
+				result = append(result, task)
+			}
+		}
+	}
+	return result
+}
+
+// UpdateFuture applies change to task, to the later occurrences of its
+// series and to the template, so occurrences still to be made get it too
+func (r *Recurrence) UpdateFuture(tt TaskTree, task *Task, change func(*Task)) {
+	for _, t := range r.occurrences(tt) {
+		if t.Occurrence >= task.Occurrence {
+			change(t)
+		}
+	}
+	change(r.Template)
+}
+
+// removeUntouched deletes the occurrences after day that have not changed
+// since they were made
+func (r *Recurrence) removeUntouched(tt TaskTree, day string) {
+	for _, t := range r.occurrences(tt) {
+		if t.Occurrence > day && untouched(t) {
+			tt.RemoveTask(t.Date, t.ID)
+		}
+	}
+}
+
+// untouched reports whether neither task nor its subtasks changed since
+// they were made
+func untouched(task *Task) bool {
+	if !task.UpdatedAt.Equal(task.CreatedAt) {
+		return false
+	}
+	for _, child := range task.Children {
+		if !untouched(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns the series with the given ID, or nil
+func (rs Recurrences) Find(id string) *Recurrence {
+	for _, r := range rs {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// Of returns the series task is an occurrence of, or nil
+func (rs Recurrences) Of(task *Task) *Recurrence {
+	if task == nil || task.SeriesID == "" {
+		return nil
+	}
+	return rs.Find(task.SeriesID)
+}
+
+// Expand makes every series' occurrences up to and including through
+func (rs Recurrences) Expand(tt TaskTree, through string) []*Task {
+	var added []*Task
+	for _, r := range rs {
+		added = append(added, r.Expand(tt, through)...)
+	}
+	return added
+}
+
+// Upcoming returns every series' occurrences still to be made up to and
+// including through, by day
+func (rs Recurrences) Upcoming(tt TaskTree, through string) TaskTree {
+	upcoming := make(TaskTree)
+	for _, r := range rs {
+		for _, task := range r.Upcoming(tt, through) {
+			upcoming.AddTask(task)
+		}
+	}
+	return upcoming
+}
+
+// Repeat makes task repeat by rule from its day on. When task already
+// belongs to a series, that series ends before task and its later
+// occurrences nobody touched are removed, so the new rule applies to this
+// and the following occurrences. It returns the series list and task's
+// series.
+func (rs Recurrences) Repeat(tt TaskTree, task *Task, rule Rule) (Recurrences, *Recurrence) {
+	if old := rs.Of(task); old != nil {
+		old.removeUntouched(tt, task.Occurrence)
+		if task.Occurrence <= old.Start {
+			old.Rule = rule
+			old.Template = templateOf(task, old.ID)
+			old.Through = firstThrough(old.Start)
+			return rs, old
+		}
+		day, _ := ParseCalendarDate(task.Occurrence)
+		old.Rule.Until, old.Rule.Count = day.AddDays(-1).String(), 0
+	}
+	r := NewRecurrence(task, rule)
+	return append(rs, r), r
+}
+
+// Stop ends task's series with task: no more occurrences are made, and the
+// later ones nobody touched are removed. A series stopped at its first
+// occurrence is removed altogether.
+func (rs Recurrences) Stop(tt TaskTree, task *Task) Recurrences {
+	r := rs.Of(task)
+	if r == nil {
+		return rs
+	}
+	r.removeUntouched(tt, task.Occurrence)
+	if task.Occurrence > r.Start {
+		r.Rule.Until, r.Rule.Count = task.Occurrence, 0
+		return rs
+	}
+	for _, t := range r.occurrences(tt) {
+		t.SeriesID, t.Occurrence = "", ""
+	}
+	return slices.DeleteFunc(rs, func(other *Recurrence) bool { return other == r })
+}
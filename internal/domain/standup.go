@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// Standup is the material for a daily standup: what happened on the
+// previous working day, what is planned for the day and what is blocked
+type Standup struct {
+	Date     CalendarDate // The standup's day
+	Previous CalendarDate // The working day before it
+
+	// Completed and Pushed are the tasks completed and pushed on Previous
+	Completed []StandupItem
+	Pushed    []StandupItem
+	// Blocked are delegated and delayed tasks: those changed on Previous
+	// that still are, then the day's own
+	Blocked []StandupItem
+	// Today are the day's open tasks, subtasks included
+	Today []StandupItem
+}
+
+// StandupItem is one line of a standup
+type StandupItem struct {
+	// Task is nil when the task was deleted after the event
+	Task *Task
+	// Title is the task's path, as in "Parent > Child"
+	Title string
+	// Event is what put the task in its section, nil for the day's tasks
+	Event *TimelineEvent
+}
+
+// PreviousWorkday returns the last weekday before d, so Friday for a
+// Monday or a weekend day
+func PreviousWorkday(d CalendarDate) CalendarDate {
+	prev := d.AddDays(-1)
+	for prev.Weekday() == time.Saturday || prev.Weekday() == time.Sunday {
+		prev = prev.AddDays(-1)
+	}
+	return prev
+}
+
+// BuildStandup assembles the standup for date. Events count for the day
+// they happened on, whichever day their task is on.
+func BuildStandup(tt TaskTree, tl Timeline, date CalendarDate) *Standup {
+	st := &Standup{Date: date, Previous: PreviousWorkday(date)}
+	previous := st.Previous.String()
+
+	var events []*TimelineEvent
+	for _, dayEvents := range tl {
+		for _, event := range dayEvents {
+			if NewCalendarDate(event.Timestamp.Local()).String() == previous {
+				events = append(events, event)
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+
+	// Newest first, so each task is listed once, with its latest event
+	completed := make(map[string]bool)
+	pushed := make(map[string]bool)
+	blocked := make(map[string]bool)
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		task := tt.FindTask(event.TaskID)
+		item := StandupItem{Task: task, Title: event.TaskTitle, Event: event}
+		if task != nil {
+			item.Title = tt.TaskPath(task)
+		}
+		switch event.Type {
+		case EventCompleted:
+			// Tasks reopened since are no longer done
+			if completed[event.TaskID] || (task != nil && task.State != TaskStateCompleted) {
+				continue
+			}
+			completed[event.TaskID] = true
+			st.Completed = append(st.Completed, item)
+		case EventPushed:
+			if pushed[event.TaskID] {
+				continue
+			}
+			pushed[event.TaskID] = true
+			st.Pushed = append(st.Pushed, item)
+		case EventDelegated, EventDelayed:
+			if blocked[event.TaskID] || task == nil || (task.State != TaskStateDelegated && task.State != TaskStateDelayed) {
+				continue
+			}
+			blocked[event.TaskID] = true
+			st.Blocked = append(st.Blocked, item)
+		}
+	}
+	slices.Reverse(st.Completed)
+	slices.Reverse(st.Pushed)
+	slices.Reverse(st.Blocked)
+
+	for _, ft := range FlattenTasks(tt[date.String()], 0, false) {
+		task := ft.Task
+		switch task.State {
+		case TaskStateTodo:
+			st.Today = append(st.Today, StandupItem{Task: task, Title: tt.TaskPath(task)})
+		case TaskStateDelegated, TaskStateDelayed:
+			if !blocked[task.ID] {
+				blocked[task.ID] = true
+				st.Blocked = append(st.Blocked, StandupItem{Task: task, Title: tt.TaskPath(task)})
+			}
+		}
+	}
+	return st
+}
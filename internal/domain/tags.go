@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// tagToken is a "#tag" word: a letter, then letters, digits, "_", "-" or
+// "/", so "#42" in "fix #42" stays part of the title
+var tagToken = regexp.MustCompile(`(^|\s)#(\p{L}(?:[\p{L}\p{N}_/-]*[\p{L}\p{N}_])?)`)
+
+// ParseTags takes the #tag words out of a typed title. It returns the
+// title without them and the tags, lowercased and without repeats, in the
+// order they appear. Text that is nothing but tags is kept as the title.
+func ParseTags(text string) (string, []string) {
+	var tags []string
+	for _, m := range tagToken.FindAllStringSubmatch(text, -1) {
+		tags = AddTag(tags, m[2])
+	}
+	if len(tags) == 0 {
+		return text, nil
+	}
+	title := strings.Join(strings.Fields(tagToken.ReplaceAllString(text, "$1")), " ")
+	if title == "" {
+		return text, nil
+	}
+	return title, tags
+}
+
+// AddTag adds tag to tags unless it is there already. Tags are lowercase.
+func AddTag(tags []string, tag string) []string {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if tag == "" || slices.Contains(tags, tag) {
+		return tags
+	}
+	return append(tags, tag)
+}
+
+// HasTag reports whether the task carries tag
+func (t *Task) HasTag(tag string) bool {
+	return slices.Contains(t.Tags, strings.ToLower(tag))
+}
+
+// TagText writes the task's tags as "#one #two", empty without tags
+func (t *Task) TagText() string {
+	if len(t.Tags) == 0 {
+		return ""
+	}
+	return "#" + strings.Join(t.Tags, " #")
+}
+
+// TitleWithTags is the title as it would be typed, with the tags after it
+func (t *Task) TitleWithTags() string {
+	if len(t.Tags) == 0 {
+		return t.Title
+	}
+	return t.Title + " " + t.TagText()
+}
+
+// TagCount counts the tasks carrying a tag, subtasks included
+type TagCount struct {
+	Tag       string
+	Open      int // Not completed
+	Completed int
+	Days      int // Days with at least one of the tasks
+}
+
+// TagCounts counts every tag in the tree, most used first
+func (tt TaskTree) TagCounts() []TagCount {
+	counts := make(map[string]*TagCount)
+	for _, tasks := range tt {
+		seen := make(map[string]bool)
+		for _, ft := range FlattenTasks(tasks, 0, false) {
+			for _, tag := range ft.Task.Tags {
+				count := counts[tag]
+				if count == nil {
+					count = &TagCount{Tag: tag}
+					counts[tag] = count
+				}
+				if ft.Task.State == TaskStateCompleted {
+					count.Completed++
+				} else {
+					count.Open++
+				}
+				if !seen[tag] {
+					seen[tag] = true
+					count.Days++
+				}
+			}
+		}
+	}
+
+	result := make([]TagCount, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, *count)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ti := result[i].Open + result[i].Completed
+		tj := result[j].Open + result[j].Completed
+		if ti != tj {
+			return ti > tj
+		}
+		return result[i].Tag < result[j].Tag
+	})
+	return result
+}
@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,6 +18,16 @@ const (
 	TaskStateDelayed   TaskState = "delayed"
 )
 
+// ParseTaskState parses a state name such as "completed"
+func ParseTaskState(s string) (TaskState, error) {
+	switch state := TaskState(strings.ToLower(strings.TrimSpace(s))); state {
+	case TaskStateTodo, TaskStateCompleted, TaskStateDelegated, TaskStateDelayed:
+		return state, nil
+	default:
+		return "", fmt.Errorf("invalid state %q (want todo, completed, delegated or delayed)", s)
+	}
+}
+
 type TaskPriority int
 
 const (
@@ -35,9 +48,13 @@ type Task struct {
 	EndTime     *time.Time   `json:"endTime,omitempty"`
 	Children    []*Task      `json:"children,omitempty"`
 	ParentID    string       `json:"parentId,omitempty"`
-	Date        string       `json:"date"`        // YYYY-MM-DD format
-	PushedCount int          `json:"pushedCount"` // Times pushed to next day
-	Expanded    bool         `json:"-"`           // UI state, not persisted
+	Date        string       `json:"date"`                 // YYYY-MM-DD format
+	DueDate     string       `json:"dueDate,omitempty"`    // Deadline, YYYY-MM-DD; Date is when it is planned
+	PushedCount int          `json:"pushedCount"`          // Times pushed to next day
+	Tags        []string     `json:"tags,omitempty"`       // Lowercase, without the "#"
+	SeriesID    string       `json:"seriesId,omitempty"`   // Recurrence this task is an occurrence of
+	Occurrence  string       `json:"occurrence,omitempty"` // Day the recurrence put it on, kept when pushed
+	Expanded    bool         `json:"-"`                    // UI state, not persisted
 }
 
 func NewTask(title, date string) *Task {
@@ -118,6 +135,18 @@ func (tt TaskTree) GetTasksForDate(date string) []*Task {
 	return make([]*Task, 0)
 }
 
+// Dates returns every date that has tasks, in chronological order
+func (tt TaskTree) Dates() []string {
+	dates := make([]string, 0, len(tt))
+	for date, tasks := range tt {
+		if len(tasks) > 0 {
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+	return dates
+}
+
 func (tt TaskTree) AddTask(task *Task) {
 	tt[task.Date] = append(tt[task.Date], task)
 }
@@ -137,6 +166,30 @@ func (tt TaskTree) RemoveTask(date, taskID string) bool {
 	return false
 }
 
+// ReplaceTask swaps the stored task that has task's ID for task, keeping
+// its position. It reports whether a task was replaced.
+func (tt TaskTree) ReplaceTask(task *Task) bool {
+	for _, tasks := range tt {
+		if replaceInSlice(tasks, task) {
+			return true
+		}
+	}
+	return false
+}
+
+func replaceInSlice(tasks []*Task, task *Task) bool {
+	for i, t := range tasks {
+		if t.ID == task.ID {
+			tasks[i] = task
+			return true
+		}
+		if replaceInSlice(t.Children, task) {
+			return true
+		}
+	}
+	return false
+}
+
 func removeTaskFromChildren(parent *Task, taskID string) bool {
 	for i, child := range parent.Children {
 		if child.ID == taskID {
@@ -181,3 +234,69 @@ func GetTaskStats(tasks []*Task) (total, completed int) {
 	}
 	return
 }
+
+// FindTask returns the task with the given ID from any date, including children
+func (tt TaskTree) FindTask(taskID string) *Task {
+	for _, tasks := range tt {
+		for _, ft := range FlattenTasks(tasks, 0, false) {
+			if ft.Task.ID == taskID {
+				return ft.Task
+			}
+		}
+	}
+	return nil
+}
+
+// TaskPath returns a task's title prefixed by its ancestors' titles, as in
+// "Parent > Child"
+func (tt TaskTree) TaskPath(task *Task) string {
+	path := task.Title
+	for parentID := task.ParentID; parentID != ""; {
+		parent := tt.FindTask(parentID)
+		if parent == nil {
+			break
+		}
+		path = parent.Title + " > " + path
+		parentID = parent.ParentID
+	}
+	return path
+}
+
+// TreeDiff counts how one task tree differs from another, matching tasks
+// by ID
+type TreeDiff struct {
+	Added   int // Only in the second tree
+	Removed int // Only in the first tree
+	Changed int // In both with a different title, state, priority or date
+}
+
+// DiffTaskTrees compares the tasks of to against those of from
+func DiffTaskTrees(from, to TaskTree) TreeDiff {
+	index := func(tt TaskTree) map[string]*Task {
+		tasks := make(map[string]*Task)
+		for _, list := range tt {
+			for _, ft := range FlattenTasks(list, 0, false) {
+				tasks[ft.Task.ID] = ft.Task
+			}
+		}
+		return tasks
+	}
+	before, after := index(from), index(to)
+
+	var diff TreeDiff
+	for id, t := range after {
+		old, ok := before[id]
+		switch {
+		case !ok:
+			diff.Added++
+		case old.Title != t.Title || old.State != t.State || old.Priority != t.Priority || old.Date != t.Date:
+			diff.Changed++
+		}
+	}
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			diff.Removed++
+		}
+	}
+	return diff
+}
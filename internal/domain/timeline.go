@@ -16,6 +16,7 @@ const (
 	EventDelayed   TimelineEventType = "delayed"
 	EventUpdated   TimelineEventType = "updated"
 	EventPushed    TimelineEventType = "pushed"
+	EventNote      TimelineEventType = "note"
 )
 
 type TimelineEvent struct {
@@ -26,6 +27,7 @@ type TimelineEvent struct {
 	Timestamp     time.Time         `json:"timestamp"`
 	PreviousState TaskState         `json:"previousState,omitempty"`
 	NewState      TaskState         `json:"newState,omitempty"`
+	Note          string            `json:"note,omitempty"` // Text of a note event
 }
 
 func NewTimelineEvent(taskID, taskTitle string, eventType TimelineEventType) *TimelineEvent {
@@ -38,6 +40,14 @@ func NewTimelineEvent(taskID, taskTitle string, eventType TimelineEventType) *Ti
 	}
 }
 
+// NewNoteEvent creates a note about a task written at the given time
+func NewNoteEvent(taskID, taskTitle, note string, at time.Time) *TimelineEvent {
+	event := NewTimelineEvent(taskID, taskTitle, EventNote)
+	event.Note = note
+	event.Timestamp = at
+	return event
+}
+
 func NewStateChangeEvent(taskID, taskTitle string, prevState, newState TaskState) *TimelineEvent {
 	var eventType TimelineEventType
 	switch newState {
@@ -108,6 +118,8 @@ func (e *TimelineEvent) GetEventIcon() string {
 		return "+"
 	case EventPushed:
 		return "↷"
+	case EventNote:
+		return "✎"
 	default:
 		return "•"
 	}
@@ -128,6 +140,8 @@ func (e *TimelineEvent) GetEventDescription() string {
 		return "created"
 	case EventPushed:
 		return "pushed to next day"
+	case EventNote:
+		return "note on"
 	default:
 		return "updated"
 	}
@@ -0,0 +1,126 @@
+// Package ipc defines the Unix socket protocol used to send commands to a
+// running seyal TUI. Each connection carries one JSON request line and one
+// JSON response line.
+package ipc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// Commands understood by the TUI
+const (
+	CommandAdd    = "add"
+	CommandState  = "state"
+	CommandPush   = "push"
+	CommandStart  = "start"
+	CommandStop   = "stop"
+	CommandUpdate = "update"
+	CommandDelete = "delete"
+	CommandReload = "reload"
+	CommandImport = "import"
+)
+
+// Request is a command sent to the TUI
+type Request struct {
+	Command  string   `json:"command"`
+	TaskID   string   `json:"taskId,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	Date     string   `json:"date,omitempty"`
+	Priority *int     `json:"priority,omitempty"`
+	ParentID string   `json:"parentId,omitempty"`
+	State    string   `json:"state,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Repeat   string   `json:"repeat,omitempty"` // RRULE the added task repeats by
+	Due      string   `json:"due,omitempty"`    // Due date of the added task, YYYY-MM-DD
+
+	// Tasks and timeline events to import, merged by the TUI as a single
+	// undoable change
+	Tasks  []*domain.Task          `json:"tasks,omitempty"`
+	Events []*domain.TimelineEvent `json:"events,omitempty"`
+}
+
+// Response is the TUI's answer to a request
+type Response struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	TaskID string `json:"taskId,omitempty"`
+}
+
+// ErrNotRunning is returned by Send when no TUI is listening
+var ErrNotRunning = errors.New("seyal TUI is not running")
+
+// timeout bounds every socket round trip
+const timeout = 5 * time.Second
+
+// SocketPath returns the per-user socket path
+func SocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "seyal.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("seyal-%d.sock", os.Getuid()))
+}
+
+// Listen creates the socket, replacing a stale one left by a crashed TUI.
+// It fails if another TUI is already listening.
+func Listen() (net.Listener, error) {
+	path := SocketPath()
+	if conn, err := net.DialTimeout("unix", path, timeout); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another seyal TUI is listening on %s", path)
+	}
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Only the owner may send commands
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Running reports whether a TUI is listening on the socket
+func Running() bool {
+	conn, err := net.DialTimeout("unix", SocketPath(), timeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Send delivers a request to the running TUI and waits for its response.
+// It returns ErrNotRunning when no TUI is listening.
+func Send(req Request) (*Response, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(), timeout)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+
+	var resp Response
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		return &resp, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,481 @@
+// Package server exposes seyal data over a local HTTP JSON API
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/ipc"
+	"github.com/krisk248/seyal/internal/storage"
+)
+
+// Server serves the REST API. Every request reloads data from disk, so
+// changes made by the TUI or the CLI in between are picked up, and every
+// write is saved (or handed to the open TUI) before the response is sent.
+type Server struct {
+	store *storage.Storage
+	mu    sync.Mutex // serializes load-modify-save cycles
+}
+
+// New creates a server backed by store
+func New(store *storage.Storage) *Server {
+	return &Server{store: store}
+}
+
+// Handler returns the HTTP handler with all API routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tasks", s.handleListTasks)
+	mux.HandleFunc("POST /api/tasks", s.handleCreateTask)
+	mux.HandleFunc("GET /api/tasks/{id}", s.handleGetTask)
+	mux.HandleFunc("PATCH /api/tasks/{id}", s.handleUpdateTask)
+	mux.HandleFunc("DELETE /api/tasks/{id}", s.handleDeleteTask)
+	mux.HandleFunc("PUT /api/tasks/{id}/state", s.handleChangeState)
+	mux.HandleFunc("POST /api/tasks/{id}/start", s.handleStart)
+	mux.HandleFunc("POST /api/tasks/{id}/stop", s.handleStop)
+	mux.HandleFunc("POST /api/tasks/{id}/push", s.handlePush)
+	mux.HandleFunc("GET /api/timeline", s.handleTimeline)
+	return guard(mux)
+}
+
+// guard keeps web pages away from the API. Requests must name a loopback
+// Host, which defeats DNS rebinding, and writes must be JSON, which a
+// cross-site form cannot send without a CORS preflight.
+func guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !loopbackHost(r.Host) {
+			writeError(w, &apiError{status: http.StatusForbidden, msg: fmt.Sprintf("host %q is not allowed", r.Host)})
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if mediaType != "application/json" {
+				writeError(w, &apiError{status: http.StatusUnsupportedMediaType, msg: "Content-Type must be application/json"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loopbackHost reports whether a Host header names this machine
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	switch strings.TrimSuffix(strings.TrimPrefix(host, "["), "]") {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// apiError is an error with an HTTP status code
+type apiError struct {
+	status int
+	msg    string
+}
+
+func (e *apiError) Error() string { return e.msg }
+
+func badRequest(format string, a ...any) error {
+	return &apiError{status: http.StatusBadRequest, msg: fmt.Sprintf(format, a...)}
+}
+
+func notFound(format string, a ...any) error {
+	return &apiError{status: http.StatusNotFound, msg: fmt.Sprintf(format, a...)}
+}
+
+func conflict(format string, a ...any) error {
+	return &apiError{status: http.StatusConflict, msg: fmt.Sprintf(format, a...)}
+}
+
+// view loads the stored data and runs fn without saving
+func (s *Server) view(fn func(schema *storage.StorageSchema) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schema, err := s.store.Load()
+	if err != nil {
+		return err
+	}
+	return fn(schema)
+}
+
+// update runs fn on freshly loaded data and saves the result under the
+// data file lock. When an open TUI holds the lock, fn runs on a copy and
+// the requests it returns are forwarded to the TUI instead, so the TUI
+// never overwrites the change. Only other holders, such as a CLI command,
+// are waited out.
+func (s *Server) update(fn func(schema *storage.StorageSchema) ([]ipc.Request, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apply := func(schema *storage.StorageSchema) error {
+		_, err := fn(schema)
+		return err
+	}
+	err := s.store.TryUpdate(apply)
+	var locked *storage.LockedError
+	if errors.As(err, &locked) && !ipc.Running() {
+		err = s.store.Update(apply)
+	}
+	if !errors.As(err, &locked) {
+		return err
+	}
+
+	schema, err := s.store.Load()
+	if err != nil {
+		return err
+	}
+	reqs, err := fn(schema)
+	if err != nil {
+		return err
+	}
+	for _, req := range reqs {
+		if _, err := ipc.Send(req); err != nil {
+			if errors.Is(err, ipc.ErrNotRunning) {
+				return &apiError{status: http.StatusLocked, msg: locked.Error()}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// handleListTasks serves GET /api/tasks?date=YYYY-MM-DD or ?from=...&to=...
+func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	inRange, err := dateRange(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	result := make(domain.TaskTree)
+	err = s.view(func(schema *storage.StorageSchema) error {
+		for _, date := range schema.Tasks.Dates() {
+			if inRange(date) {
+				result[date] = schema.Tasks.GetTasksForDate(date)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleGetTask serves GET /api/tasks/{id}
+func (s *Server) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	var task *domain.Task
+	err := s.view(func(schema *storage.StorageSchema) error {
+		var err error
+		task, err = findTask(schema, r.PathValue("id"))
+		return err
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+// createRequest is the body of POST /api/tasks
+type createRequest struct {
+	Title    string              `json:"title"`
+	Date     string              `json:"date"`
+	Priority domain.TaskPriority `json:"priority"`
+	ParentID string              `json:"parentId"`
+}
+
+// handleCreateTask serves POST /api/tasks
+func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
+	var req createRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		writeError(w, badRequest("title is required"))
+		return
+	}
+	if req.Date == "" {
+		req.Date = domain.Today().String()
+	}
+	if _, err := domain.ParseCalendarDate(req.Date); err != nil {
+		writeError(w, badRequest("%v", err))
+		return
+	}
+	if err := validatePriority(req.Priority); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var task *domain.Task
+	err := s.update(func(schema *storage.StorageSchema) ([]ipc.Request, error) {
+		var parent *domain.Task
+		if req.ParentID != "" {
+			var err error
+			if parent, err = findTask(schema, req.ParentID); err != nil {
+				return nil, err
+			}
+		}
+		title, tags := domain.ParseTags(req.Title)
+		task = domain.NewTask(title, req.Date)
+		task.Tags = tags
+		if req.Priority != domain.PriorityNone {
+			task.SetPriority(req.Priority)
+		}
+		domain.AddNewTask(schema.Tasks, schema.Timeline, task, parent)
+
+		remote := ipc.Request{Command: ipc.CommandAdd, TaskID: task.ID, Title: task.Title, Date: task.Date, ParentID: req.ParentID, Tags: task.Tags}
+		if req.Priority != domain.PriorityNone {
+			p := int(req.Priority)
+			remote.Priority = &p
+		}
+		return []ipc.Request{remote}, nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, task)
+}
+
+// updateRequest is the body of PATCH /api/tasks/{id}; omitted fields are unchanged
+type updateRequest struct {
+	Title    *string              `json:"title"`
+	Priority *domain.TaskPriority `json:"priority"`
+}
+
+// handleUpdateTask serves PATCH /api/tasks/{id}
+func (s *Server) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
+	var req updateRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Title != nil && strings.TrimSpace(*req.Title) == "" {
+		writeError(w, badRequest("title cannot be empty"))
+		return
+	}
+	if req.Priority != nil {
+		if err := validatePriority(*req.Priority); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	s.mutateTask(w, r, func(schema *storage.StorageSchema, task *domain.Task) (ipc.Request, error) {
+		remote := ipc.Request{Command: ipc.CommandUpdate}
+		// Title and priority edits are not logged, matching the TUI
+		if req.Title != nil {
+			task.Title, task.Tags = domain.ParseTags(strings.TrimSpace(*req.Title))
+			task.UpdatedAt = time.Now()
+			remote.Title, remote.Tags = task.Title, task.Tags
+		}
+		if req.Priority != nil {
+			task.SetPriority(*req.Priority)
+			p := int(*req.Priority)
+			remote.Priority = &p
+		}
+		return remote, nil
+	})
+}
+
+// stateRequest is the body of PUT /api/tasks/{id}/state
+type stateRequest struct {
+	State string `json:"state"`
+}
+
+// handleChangeState serves PUT /api/tasks/{id}/state
+func (s *Server) handleChangeState(w http.ResponseWriter, r *http.Request) {
+	var req stateRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	state, err := domain.ParseTaskState(req.State)
+	if err != nil {
+		writeError(w, badRequest("%v", err))
+		return
+	}
+
+	s.mutateTask(w, r, func(schema *storage.StorageSchema, task *domain.Task) (ipc.Request, error) {
+		domain.ChangeTaskState(schema.Timeline, task, state)
+		return ipc.Request{Command: ipc.CommandState, State: string(state)}, nil
+	})
+}
+
+// handleStart serves POST /api/tasks/{id}/start
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
+	s.mutateTask(w, r, func(schema *storage.StorageSchema, task *domain.Task) (ipc.Request, error) {
+		if task.IsRunning() {
+			return ipc.Request{}, conflict("task is already running")
+		}
+		domain.StartTask(schema.Timeline, task)
+		return ipc.Request{Command: ipc.CommandStart}, nil
+	})
+}
+
+// handleStop serves POST /api/tasks/{id}/stop
+func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	s.mutateTask(w, r, func(schema *storage.StorageSchema, task *domain.Task) (ipc.Request, error) {
+		if !task.IsRunning() {
+			return ipc.Request{}, conflict("task is not running")
+		}
+		task.Stop()
+		return ipc.Request{Command: ipc.CommandStop}, nil
+	})
+}
+
+// handlePush serves POST /api/tasks/{id}/push
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	s.mutateTask(w, r, func(schema *storage.StorageSchema, task *domain.Task) (ipc.Request, error) {
+		_, err := domain.PushTask(schema.Tasks, schema.Timeline, task)
+		return ipc.Request{Command: ipc.CommandPush}, err
+	})
+}
+
+// handleDeleteTask serves DELETE /api/tasks/{id}
+func (s *Server) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
+	err := s.update(func(schema *storage.StorageSchema) ([]ipc.Request, error) {
+		task, err := findTask(schema, r.PathValue("id"))
+		if err != nil {
+			return nil, err
+		}
+		// Keep timeline events for deleted tasks as historical log
+		schema.Tasks.RemoveTask(task.Date, task.ID)
+		return []ipc.Request{{Command: ipc.CommandDelete, TaskID: task.ID}}, nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTimeline serves GET /api/timeline?date=YYYY-MM-DD
+func (s *Server) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = domain.Today().String()
+	}
+	if _, err := domain.ParseCalendarDate(date); err != nil {
+		writeError(w, badRequest("%v", err))
+		return
+	}
+
+	var events []*domain.TimelineEvent
+	err := s.view(func(schema *storage.StorageSchema) error {
+		events = schema.Timeline.GetEventsForDate(date)
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// mutateTask looks up the task in the request path, applies fn, saves and
+// responds with the updated task. fn returns the equivalent request for an
+// open TUI.
+func (s *Server) mutateTask(w http.ResponseWriter, r *http.Request, fn func(schema *storage.StorageSchema, task *domain.Task) (ipc.Request, error)) {
+	var task *domain.Task
+	err := s.update(func(schema *storage.StorageSchema) ([]ipc.Request, error) {
+		var err error
+		if task, err = findTask(schema, r.PathValue("id")); err != nil {
+			return nil, err
+		}
+		remote, err := fn(schema, task)
+		remote.TaskID = task.ID
+		return []ipc.Request{remote}, err
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+func findTask(schema *storage.StorageSchema, id string) (*domain.Task, error) {
+	task := schema.Tasks.FindTask(id)
+	if task == nil {
+		return nil, notFound("task %q not found", id)
+	}
+	return task, nil
+}
+
+// dateRange parses ?date= or ?from=&to= into a date predicate. Without any
+// parameter only today is selected.
+func dateRange(r *http.Request) (func(string) bool, error) {
+	q := r.URL.Query()
+	date, from, to := q.Get("date"), q.Get("from"), q.Get("to")
+	if date != "" && (from != "" || to != "") {
+		return nil, badRequest("date cannot be combined with from or to")
+	}
+	for _, d := range []string{date, from, to} {
+		if d == "" {
+			continue
+		}
+		if _, err := domain.ParseCalendarDate(d); err != nil {
+			return nil, badRequest("%v", err)
+		}
+	}
+
+	if from == "" && to == "" {
+		if date == "" {
+			date = domain.Today().String()
+		}
+		return func(d string) bool { return d == date }, nil
+	}
+	// YYYY-MM-DD strings sort chronologically
+	return func(d string) bool {
+		return (from == "" || d >= from) && (to == "" || d <= to)
+	}, nil
+}
+
+func validatePriority(p domain.TaskPriority) error {
+	if p < domain.PriorityNone || p > domain.PriorityLow {
+		return badRequest("invalid priority %d (want 0-3)", p)
+	}
+	return nil
+}
+
+func decodeBody(r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return badRequest("invalid JSON body: %v", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		status = apiErr.status
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// Backend is the storage interface implemented by each on-disk format.
+// Storage picks one when it is created and forwards to it. Callers that
+// change data must hold the data file lock.
+type Backend interface {
+	// Kind names the format
+	Kind() BackendKind
+	// Load reads all stored data
+	Load() (*StorageSchema, error)
+	// Save replaces all stored data with schema
+	Save(schema *StorageSchema) error
+	// GetTask returns a task with its subtasks, or ErrTaskNotFound
+	GetTask(id string) (*domain.Task, error)
+	// PutTask creates or replaces a task and its subtasks. A task with a
+	// ParentID is stored as a child of that task.
+	PutTask(task *domain.Task) error
+	// DeleteTask removes a task and its subtasks
+	DeleteTask(id string) error
+	// AppendEvent adds an event to the timeline of date
+	AppendEvent(date string, event *domain.TimelineEvent) error
+}
+
+// BackendKind names a storage format
+type BackendKind string
+
+const (
+	BackendJSON   BackendKind = "json"
+	BackendSQLite BackendKind = "sqlite"
+)
+
+// ErrTaskNotFound is returned when a task ID is not stored
+var ErrTaskNotFound = errors.New("task not found")
+
+// ParseBackendKind parses a backend name as given on the command line
+func ParseBackendKind(s string) (BackendKind, error) {
+	switch kind := BackendKind(s); kind {
+	case BackendJSON, BackendSQLite:
+		return kind, nil
+	}
+	return "", fmt.Errorf("unknown storage backend %q (want json or sqlite)", s)
+}
+
+func newBackend(kind BackendKind, path string) Backend {
+	if kind == BackendSQLite {
+		return &sqliteBackend{path: path}
+	}
+	return &jsonBackend{path: path}
+}
+
+// sqlitePath returns the database path that lives next to data.json
+func sqlitePath(jsonPath string) string {
+	return filepath.Join(filepath.Dir(jsonPath), "data.db")
+}
+
+// GetTask returns a stored task with its subtasks
+func (s *Storage) GetTask(id string) (*domain.Task, error) {
+	return s.backend.GetTask(id)
+}
+
+// PutTask creates or replaces a task and its subtasks
+func (s *Storage) PutTask(task *domain.Task) error {
+	return s.backend.PutTask(task)
+}
+
+// DeleteTask removes a task and its subtasks
+func (s *Storage) DeleteTask(id string) error {
+	return s.backend.DeleteTask(id)
+}
+
+// AppendEvent adds an event to the timeline of date
+func (s *Storage) AppendEvent(date string, event *domain.TimelineEvent) error {
+	return s.backend.AppendEvent(date, event)
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat is the timestamp in backup file names
+const backupTimeFormat = "20060102-150405"
+
+// BackupRetention says how many automatic backups to keep: the newest
+// backup of each of the last Daily days, Weekly ISO weeks and Monthly
+// months. All zero turns automatic backups off.
+type BackupRetention struct {
+	Daily   int `json:"daily"`
+	Weekly  int `json:"weekly"`
+	Monthly int `json:"monthly"`
+}
+
+// DefaultBackupRetention is used when the settings do not configure one
+var DefaultBackupRetention = BackupRetention{Daily: 7, Weekly: 4, Monthly: 12}
+
+// BackupRetention returns the configured retention or the default
+func (s Settings) BackupRetention() BackupRetention {
+	if s.Backups == nil {
+		return DefaultBackupRetention
+	}
+	return *s.Backups
+}
+
+// BackupTime returns when the backup at path was taken, from its name
+func (s *Storage) BackupTime(path string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(path, s.DataPath+".backup-")
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+	return t, err == nil
+}
+
+// LoadBackup reads a backup without touching the data file
+func (s *Storage) LoadBackup(path string) (*StorageSchema, error) {
+	return newBackend(s.Kind(), path).Load()
+}
+
+// dailyBackup backs the data file up unless that already happened today,
+// then prunes old backups
+func (s *Storage) dailyBackup(retention BackupRetention) error {
+	if retention == (BackupRetention{}) {
+		return nil
+	}
+
+	backups, err := s.Backups()
+	if err != nil {
+		return err
+	}
+	today := time.Now().Format("20060102")
+	if len(backups) == 0 || !strings.HasPrefix(backups[0], s.DataPath+".backup-"+today) {
+		if err := s.Backup(); err != nil {
+			return err
+		}
+	}
+	return s.PruneBackups(retention)
+}
+
+// PruneBackups deletes the backups the retention does not keep. Files
+// whose names carry no timestamp are left alone.
+func (s *Storage) PruneBackups(retention BackupRetention) error {
+	backups, err := s.Backups()
+	if err != nil {
+		return err
+	}
+
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	months := make(map[string]bool)
+	keep := func(seen map[string]bool, key string, limit int) bool {
+		if seen[key] || len(seen) >= limit {
+			return false
+		}
+		seen[key] = true
+		return true
+	}
+
+	// Newest first, so the first backup seen in a period is its newest
+	for _, path := range backups {
+		t, ok := s.BackupTime(path)
+		if !ok {
+			continue
+		}
+		year, week := t.ISOWeek()
+		kept := keep(days, t.Format("2006-01-02"), retention.Daily)
+		kept = keep(weeks, fmt.Sprintf("%d-W%02d", year, week), retention.Weekly) || kept
+		kept = keep(months, t.Format("2006-01"), retention.Monthly) || kept
+		if !kept {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// Convert copies all data into a new file of the given backend next to the
+// current one, checks that it reads back identically and then moves the
+// old file aside. It returns the new storage and where the old file went.
+func (s *Storage) Convert(kind BackendKind) (*Storage, string, error) {
+	if kind == s.Kind() {
+		return nil, "", fmt.Errorf("already using %s storage (%s)", kind, s.DataPath)
+	}
+
+	lock, err := s.LockWait(2 * time.Second)
+	if err != nil {
+		return nil, "", err
+	}
+	defer lock.Unlock()
+
+	schema, err := s.Load()
+	if err != nil {
+		return nil, "", err
+	}
+	if schema.IsNewer() {
+		return nil, "", &VersionError{Path: s.DataPath, Version: schema.Version}
+	}
+
+	target := filepath.Join(filepath.Dir(s.DataPath), "data.json")
+	if kind == BackendSQLite {
+		target = sqlitePath(s.DataPath)
+	}
+	if _, err := os.Stat(target); err == nil {
+		return nil, "", fmt.Errorf("%s already exists", target)
+	}
+
+	dst := OpenStorage(kind, target)
+	if err := dst.Save(schema); err != nil {
+		removeConverted(target)
+		return nil, "", err
+	}
+	got, err := dst.Load()
+	if err == nil && !sameData(schema, got) {
+		err = errors.New("converted data does not match the original")
+	}
+	if err != nil {
+		removeConverted(target)
+		return nil, "", fmt.Errorf("%w; %s was left unchanged", err, s.DataPath)
+	}
+
+	moved := s.DataPath + ".migrated-" + time.Now().Format("20060102-150405")
+	if err := os.Rename(s.DataPath, moved); err != nil {
+		removeConverted(target)
+		return nil, "", err
+	}
+	return dst, moved, nil
+}
+
+// removeConverted deletes a partly written conversion target
+func removeConverted(path string) {
+	os.Remove(path)
+	os.Remove(path + "-journal")
+}
+
+// sameData reports whether two schemas hold the same tasks, timeline and
+// settings. Days without entries and parent references that do not match
+// the tree carry no data and are ignored.
+func sameData(a, b *StorageSchema) bool {
+	encode := func(schema *StorageSchema) []byte {
+		tasks := make(domain.TaskTree)
+		for date, list := range schema.Tasks {
+			if len(list) > 0 {
+				tasks[date] = list
+				for _, task := range list {
+					normalizeParents(task, "")
+				}
+			}
+		}
+		timeline := make(domain.Timeline)
+		for date, events := range schema.Timeline {
+			if len(events) > 0 {
+				timeline[date] = events
+			}
+		}
+		data, _ := json.Marshal(StorageSchema{Tasks: tasks, Timeline: timeline, Recurrences: schema.Recurrences, Settings: schema.Settings})
+		return data
+	}
+	return string(encode(a)) == string(encode(b))
+}
+
+// normalizeParents sets every ParentID to the task's place in the tree
+func normalizeParents(task *domain.Task, parentID string) {
+	task.ParentID = parentID
+	for _, child := range task.Children {
+		normalizeParents(child, task.ID)
+	}
+}
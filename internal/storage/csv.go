@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// CSVOptions controls the CSV timesheet export
+type CSVOptions struct {
+	// Delimiter separates fields; empty means the locale's default (see
+	// DefaultCSVDelimiter)
+	Delimiter string `json:"delimiter,omitempty"`
+	// RFC4180 quotes every field and ends lines with CRLF. Otherwise
+	// fields are quoted only when they must be and lines end with LF.
+	RFC4180 bool `json:"rfc4180,omitempty"`
+}
+
+// CSVDelimiters are the delimiters the export dialog cycles through
+var CSVDelimiters = []string{",", ";", "\t"}
+
+// decimalCommaLanguages write numbers like 1,5, so spreadsheets in those
+// locales expect ";" between CSV fields
+var decimalCommaLanguages = map[string]bool{
+	"bg": true, "cs": true, "da": true, "de": true, "el": true, "es": true,
+	"et": true, "fi": true, "fr": true, "hr": true, "hu": true, "id": true,
+	"it": true, "lt": true, "lv": true, "nb": true, "nl": true, "nn": true,
+	"pl": true, "pt": true, "ro": true, "ru": true, "sk": true, "sl": true,
+	"sr": true, "sv": true, "tr": true, "uk": true, "vi": true,
+}
+
+// DefaultCSVDelimiter returns ";" for locales with a decimal comma and ","
+// otherwise, going by LC_ALL, LC_NUMERIC and LANG
+func DefaultCSVDelimiter() string {
+	for _, name := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		locale := os.Getenv(name)
+		if locale == "" {
+			continue
+		}
+		lang, _, _ := strings.Cut(locale, "_")
+		lang, _, _ = strings.Cut(lang, ".")
+		if decimalCommaLanguages[strings.ToLower(lang)] {
+			return ";"
+		}
+		return ","
+	}
+	return ","
+}
+
+// delimiter returns the delimiter to write
+func (o CSVOptions) delimiter() string {
+	if o.Delimiter == "" {
+		return DefaultCSVDelimiter()
+	}
+	return o.Delimiter
+}
+
+// csvTimeFormat is used for the start and end columns
+const csvTimeFormat = "2006-01-02 15:04"
+
+// exportCSV writes a timesheet: one row per task, subtasks included, with
+// its tracked time, and a totals row after each day
+func (s *Storage) exportCSV(tasks domain.TaskTree, dates domain.DateRange, opts CSVOptions) (string, error) {
+	w := csvWriter{opts: opts, delimiter: opts.delimiter()}
+	w.row("date", "task", "state", "priority", "pushed", "start", "end", "duration_minutes", "tags")
+
+	for _, date := range exportDates(tasks, dates) {
+		var total time.Duration
+		for _, ft := range domain.FlattenTasks(tasks[date], 0, false) {
+			task := ft.Task
+			start, end, minutes := "", "", ""
+			if task.StartTime != nil {
+				start = task.StartTime.Local().Format(csvTimeFormat)
+			}
+			if task.EndTime != nil {
+				end = task.EndTime.Local().Format(csvTimeFormat)
+			}
+			if d, ok := trackedTime(task); ok {
+				total += d
+				minutes = fmt.Sprint(int(d.Round(time.Minute).Minutes()))
+			}
+			w.row(date, tasks.TaskPath(task), string(task.State), priorityLabel(task.Priority),
+				fmt.Sprint(task.PushedCount), start, end, minutes, strings.Join(task.Tags, " "))
+		}
+		w.row(date, "Total", "", "", "", "", "", fmt.Sprint(int(total.Round(time.Minute).Minutes())), "")
+	}
+	return w.String(), nil
+}
+
+// trackedTime returns how long a stopped task ran. Tasks that were never
+// started, or are still running, have no tracked time.
+func trackedTime(task *domain.Task) (time.Duration, bool) {
+	if task.StartTime == nil || task.EndTime == nil || task.EndTime.Before(*task.StartTime) {
+		return 0, false
+	}
+	return task.EndTime.Sub(*task.StartTime), true
+}
+
+// priorityLabel returns "P1" to "P3", or "" without a priority
+func priorityLabel(p domain.TaskPriority) string {
+	if p == domain.PriorityNone {
+		return ""
+	}
+	return fmt.Sprintf("P%d", p)
+}
+
+// csvWriter builds CSV rows. encoding/csv cannot quote every field, which
+// the RFC 4180 option needs.
+type csvWriter struct {
+	strings.Builder
+	opts      CSVOptions
+	delimiter string
+}
+
+func (w *csvWriter) row(fields ...string) {
+	for i, field := range fields {
+		if i > 0 {
+			w.WriteString(w.delimiter)
+		}
+		if w.opts.RFC4180 || strings.ContainsAny(field, w.delimiter+"\"\r\n") {
+			field = `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+		}
+		w.WriteString(field)
+	}
+	if w.opts.RFC4180 {
+		w.WriteString("\r\n")
+	} else {
+		w.WriteString("\n")
+	}
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/theme"
+)
+
+// htmlReport is the data the HTML report template renders
+type htmlReport struct {
+	Title  string
+	Colors map[string]string
+	Days   []htmlDay
+}
+
+type htmlDay struct {
+	Date      string
+	Heading   string
+	Total     int
+	Completed int
+	Percent   int
+	Tasks     []*domain.Task
+	Events    []*domain.TimelineEvent
+}
+
+// exportHTML writes a single self-contained HTML page with the tasks and
+// timeline of the days in dates, coloured like the TUI with colors (the
+// default theme when nil)
+func (s *Storage) exportHTML(tasks domain.TaskTree, tl domain.Timeline, dates domain.DateRange, colors *theme.ColorScheme) (string, error) {
+	if colors == nil {
+		colors = &theme.Ultraviolet.Colors
+	}
+	c := *colors
+	report := htmlReport{
+		Title: "seyal report: " + dates.String(),
+		Colors: map[string]string{
+			"background": string(c.Background),
+			"surface":    string(c.Surface),
+			"border":     string(c.Border),
+			"text":       string(c.TextPrimary),
+			"text2":      string(c.TextSecondary),
+			"muted":      string(c.TextMuted),
+			"primary":    string(c.Primary),
+			"secondary":  string(c.Secondary),
+			"success":    string(c.Success),
+			"warning":    string(c.Warning),
+			"todo":       string(c.TaskTodo),
+			"completed":  string(c.TaskCompleted),
+			"delegated":  string(c.TaskDelegated),
+			"delayed":    string(c.TaskDelayed),
+			"running":    string(c.TaskRunning),
+			"p1":         string(c.PriorityHigh),
+			"p2":         string(c.PriorityMed),
+			"p3":         string(c.PriorityLow),
+			"connector":  string(c.TimelineConnector),
+			"timestamp":  string(c.TimelineTimestamp),
+		},
+	}
+	if dates.IsAll() {
+		report.Title = "seyal report"
+	}
+
+	for _, date := range reportDates(tasks, tl, dates) {
+		day := htmlDay{Date: date, Heading: date, Tasks: tasks[date], Events: sortedEvents(tl[date])}
+		if d, err := domain.ParseCalendarDate(date); err == nil {
+			day.Heading = d.Format("Monday, January 2, 2006")
+		}
+		day.Total, day.Completed = domain.GetTaskStats(day.Tasks)
+		if day.Total > 0 {
+			day.Percent = day.Completed * 100 / day.Total
+		}
+		report.Days = append(report.Days, day)
+	}
+
+	var b strings.Builder
+	if err := htmlReportTemplate.Execute(&b, report); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// taskCheckbox returns the checkbox the TUI shows for a task's state
+func taskCheckbox(task *domain.Task) string {
+	switch task.State {
+	case domain.TaskStateCompleted:
+		return "[✓]"
+	case domain.TaskStateDelegated:
+		return "[→]"
+	case domain.TaskStateDelayed:
+		return "[‖]"
+	default:
+		return "[ ]"
+	}
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"checkbox": taskCheckbox,
+	"priority": priorityLabel,
+	"clock": func(t time.Time) string {
+		return t.Local().Format("3:04 PM")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+:root {
+{{- range $name, $color := .Colors}}
+  --{{$name}}: {{$color}};
+{{- end}}
+}
+body { background: var(--background); color: var(--text); font: 15px/1.5 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; margin: 0; padding: 2rem; }
+main { max-width: 60rem; margin: 0 auto; }
+h1 { color: var(--primary); font-size: 1.4rem; }
+section { background: var(--surface); border: 1px solid var(--border); border-radius: 6px; padding: 1rem 1.5rem; margin: 1.5rem 0; }
+h2 { color: var(--secondary); font-size: 1.1rem; margin: 0 0 .5rem; display: flex; justify-content: space-between; }
+.progress { height: 6px; background: var(--border); border-radius: 3px; margin-bottom: 1rem; }
+.progress div { height: 100%; background: var(--success); border-radius: 3px; }
+.percent { color: var(--text2); font-weight: normal; }
+.columns { display: grid; grid-template-columns: 3fr 2fr; gap: 2rem; }
+h3 { color: var(--muted); font-size: .8rem; text-transform: uppercase; letter-spacing: .05em; margin: 0 0 .5rem; }
+ul { list-style: none; margin: 0; padding: 0; }
+ul ul { padding-left: 1.5rem; }
+li { margin: .15rem 0; }
+.icon { display: inline-block; min-width: 1.2rem; margin-right: .4rem; }
+.todo .icon { color: var(--todo); }
+.completed .icon { color: var(--completed); }
+.completed > .title { color: var(--muted); text-decoration: line-through; }
+.delegated .icon { color: var(--delegated); }
+.delayed .icon { color: var(--delayed); }
+.running { color: var(--running); margin-left: .4rem; }
+.badge { font-size: .75rem; font-weight: bold; margin-right: .4rem; }
+.P1 { color: var(--p1); }
+.P2 { color: var(--p2); }
+.P3 { color: var(--p3); }
+.pushed { color: var(--warning); font-size: .75rem; margin-left: .4rem; }
+.tag { background: var(--border); color: var(--text2); border-radius: 3px; font-size: .75rem; padding: 0 .35rem; margin-left: .4rem; }
+.timeline li { border-left: 2px solid var(--connector); padding-left: .6rem; margin: 0 0 .4rem .3rem; }
+.timeline time { display: block; color: var(--timestamp); font-size: .75rem; }
+.empty { color: var(--muted); }
+footer { color: var(--muted); font-size: .75rem; text-align: center; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+{{- range .Days}}
+<section>
+  <h2>{{.Heading}} <span class="percent">{{.Completed}}/{{.Total}} done · {{.Percent}}%</span></h2>
+  <div class="progress"><div style="width: {{.Percent}}%"></div></div>
+  <div class="columns">
+    <div>
+      <h3>Tasks</h3>
+      {{- if .Tasks}}
+      {{template "tasks" .Tasks}}
+      {{- else}}
+      <p class="empty">No tasks.</p>
+      {{- end}}
+    </div>
+    <div class="timeline">
+      <h3>Timeline</h3>
+      {{- if .Events}}
+      <ul>
+      {{- range .Events}}
+        <li><span class="icon">{{.GetEventIcon}}</span>{{.GetEventDescription}} {{.TaskTitle}}{{if .Note}}: {{.Note}}{{end}}<time>{{clock .Timestamp}}</time></li>
+      {{- end}}
+      </ul>
+      {{- else}}
+      <p class="empty">No activity.</p>
+      {{- end}}
+    </div>
+  </div>
+</section>
+{{- else}}
+<p class="empty">No tasks in this range.</p>
+{{- end}}
+<footer>Exported from seyal</footer>
+</main>
+</body>
+</html>
+{{define "tasks"}}<ul>
+{{- range .}}
+  <li class="{{.State}}"><span class="icon">{{checkbox .}}</span>
+  {{- with priority .Priority}}<span class="badge {{.}}">{{.}}</span>{{end}}<span class="title">{{.Title}}</span>
+  {{- range .Tags}}<span class="tag">#{{.}}</span>{{end}}
+  {{- if .IsRunning}}<span class="running">●</span>{{end}}
+  {{- if .PushedCount}}<span class="pushed">[↷{{.PushedCount}}]</span>{{end}}
+  {{- if .Children}}{{template "tasks" .Children}}{{end}}</li>
+{{- end}}
+</ul>{{end}}
+`))
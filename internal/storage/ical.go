@@ -0,0 +1,381 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// iCalendar (RFC 5545) support. Every task, subtask included, becomes a
+// VTODO whose UID is the task ID, so a file exported here can be edited in
+// a calendar client and merged back by UID.
+
+const (
+	icalDateFormat     = "20060102"
+	icalDateTimeFormat = "20060102T150405Z"
+	// X-SEYAL-STATE keeps the states STATUS has no value for
+	icalStateProperty = "X-SEYAL-STATE"
+)
+
+// exportICalendar writes the tasks of the days in dates as a VCALENDAR
+func (s *Storage) exportICalendar(tasks domain.TaskTree, dates domain.DateRange) (string, error) {
+	var w icalWriter
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//seyal//seyal//EN")
+
+	stamp := time.Now().UTC().Format(icalDateTimeFormat)
+	for _, date := range exportDates(tasks, dates) {
+		for _, ft := range domain.FlattenTasks(tasks[date], 0, false) {
+			writeVTodo(&w, ft.Task, stamp)
+		}
+	}
+
+	w.line("END", "VCALENDAR")
+	return w.String(), nil
+}
+
+func writeVTodo(w *icalWriter, task *domain.Task, stamp string) {
+	w.line("BEGIN", "VTODO")
+	w.line("UID", icalText(task.ID))
+	w.line("DTSTAMP", stamp)
+	w.line("CREATED", task.CreatedAt.UTC().Format(icalDateTimeFormat))
+	w.line("LAST-MODIFIED", task.UpdatedAt.UTC().Format(icalDateTimeFormat))
+	w.line("SUMMARY", icalText(task.Title))
+
+	// A task starts on its planned day. DUE, the deadline, is a day too,
+	// the way to-do clients write an all-day due date.
+	if day, err := domain.ParseCalendarDate(task.Date); err == nil {
+		w.line("DTSTART;VALUE=DATE", day.Time().Format(icalDateFormat))
+	}
+	if due, err := domain.ParseCalendarDate(task.DueDate); err == nil {
+		w.line("DUE;VALUE=DATE", due.Time().Format(icalDateFormat))
+	}
+
+	switch task.State {
+	case domain.TaskStateCompleted:
+		w.line("STATUS", "COMPLETED")
+		w.line("PERCENT-COMPLETE", "100")
+		if task.EndTime != nil {
+			w.line("COMPLETED", task.EndTime.UTC().Format(icalDateTimeFormat))
+		}
+	case domain.TaskStateDelegated:
+		w.line("STATUS", "IN-PROCESS")
+		w.line(icalStateProperty, string(task.State))
+	case domain.TaskStateDelayed:
+		w.line("STATUS", "NEEDS-ACTION")
+		w.line(icalStateProperty, string(task.State))
+	default:
+		w.line("STATUS", "NEEDS-ACTION")
+	}
+
+	if p := icalPriority(task.Priority); p != 0 {
+		w.line("PRIORITY", strconv.Itoa(p))
+	}
+	if task.ParentID != "" {
+		w.line("RELATED-TO;RELTYPE=PARENT", icalText(task.ParentID))
+	}
+	if len(task.Tags) > 0 {
+		categories := make([]string, len(task.Tags))
+		for i, tag := range task.Tags {
+			categories[i] = icalText(tag)
+		}
+		w.line("CATEGORIES", strings.Join(categories, ","))
+	}
+	w.line("END", "VTODO")
+}
+
+// icalPriority maps P1-P3 onto the high (1), medium (5) and low (9) ends
+// of the 1-9 PRIORITY scale
+func icalPriority(p domain.TaskPriority) int {
+	switch p {
+	case domain.PriorityHigh:
+		return 1
+	case domain.PriorityMed:
+		return 5
+	case domain.PriorityLow:
+		return 9
+	default:
+		return 0
+	}
+}
+
+// taskPriority maps a PRIORITY value back, following the RFC 5545 bands
+func taskPriority(p int) domain.TaskPriority {
+	switch {
+	case p >= 1 && p <= 4:
+		return domain.PriorityHigh
+	case p == 5:
+		return domain.PriorityMed
+	case p >= 6 && p <= 9:
+		return domain.PriorityLow
+	default:
+		return domain.PriorityNone
+	}
+}
+
+// icalWriter builds CRLF-terminated content lines, folded at 75 octets
+type icalWriter struct {
+	strings.Builder
+}
+
+func (w *icalWriter) line(name, value string) {
+	line := name + ":" + value
+	for len(line) > 75 {
+		cut := 75
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	w.WriteString(line + "\r\n")
+}
+
+var icalTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "")
+
+// icalText escapes a TEXT value
+func icalText(s string) string {
+	return icalTextEscaper.Replace(s)
+}
+
+// icalUnescape reverses icalText
+func icalUnescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// icalList splits a list of TEXT values at the commas that are not escaped
+func icalList(s string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, icalUnescape(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, icalUnescape(s[start:]))
+}
+
+// icalProperty is one parsed content line
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICalendar reads the VTODO components of an iCalendar file. Other
+// components are ignored. Subtasks are nested under their RELATED-TO
+// parent (see nestTasks). DUE is the due date. VTODOs go on their start
+// day, else on their due day, else on date.
+func parseICalendar(r io.Reader, date string) ([]*domain.Task, error) {
+	lines, err := icalLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []*domain.Task
+	seen := make(map[string]bool)
+
+	var todo []icalProperty
+	inTodo := false
+	for i, line := range lines {
+		prop, err := parseICalLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VTODO"):
+			inTodo, todo = true, nil
+		case prop.name == "END" && strings.EqualFold(prop.value, "VTODO"):
+			inTodo = false
+			task, err := vtodoTask(todo, date)
+			if err != nil {
+				return nil, err
+			}
+			if seen[task.ID] {
+				continue // The first VTODO with a UID wins
+			}
+			seen[task.ID] = true
+			tasks = append(tasks, task)
+		case inTodo:
+			todo = append(todo, prop)
+		}
+	}
+
+	return nestTasks(tasks), nil
+}
+
+// vtodoTask builds a task from the properties of one VTODO, with the UID
+// of its parent, if any, as ParentID
+func vtodoTask(props []icalProperty, date string) (*domain.Task, error) {
+	task := domain.NewTask("Untitled task", date)
+	var seyalState string
+	var start, due string
+	for _, p := range props {
+		switch p.name {
+		case "UID":
+			if id := icalUnescape(p.value); id != "" {
+				task.ID = id
+			}
+		case "SUMMARY":
+			if title := strings.TrimSpace(icalUnescape(p.value)); title != "" {
+				task.Title = title
+			}
+		case "DTSTART":
+			start = icalDate(p)
+		case "DUE":
+			due = icalDate(p)
+		case "STATUS":
+			if strings.EqualFold(p.value, "COMPLETED") {
+				task.State = domain.TaskStateCompleted
+			}
+		case icalStateProperty:
+			seyalState = p.value
+		case "PRIORITY":
+			n, err := strconv.Atoi(strings.TrimSpace(p.value))
+			if err != nil {
+				return nil, fmt.Errorf("VTODO %s: invalid PRIORITY %q", task.ID, p.value)
+			}
+			task.Priority = taskPriority(n)
+		case "CATEGORIES":
+			// Tags cannot hold spaces, so "Client work" becomes client-work
+			for _, category := range icalList(p.value) {
+				task.Tags = domain.AddTag(task.Tags, strings.Join(strings.Fields(category), "-"))
+			}
+		case "RELATED-TO":
+			if rel := p.params["RELTYPE"]; rel == "" || strings.EqualFold(rel, "PARENT") {
+				task.ParentID = icalUnescape(p.value)
+			}
+		case "CREATED":
+			if t, ok := icalTime(p); ok {
+				task.CreatedAt = t
+			}
+		case "LAST-MODIFIED":
+			if t, ok := icalTime(p); ok {
+				task.UpdatedAt = t
+			}
+		case "COMPLETED":
+			if t, ok := icalTime(p); ok {
+				task.EndTime = &t
+			}
+		}
+	}
+
+	if state, err := domain.ParseTaskState(seyalState); err == nil {
+		task.State = state
+	}
+	if task.State != domain.TaskStateCompleted {
+		task.EndTime = nil
+	}
+	switch {
+	case start != "":
+		task.Date = start
+	case due != "":
+		task.Date = due
+	}
+	task.DueDate = due
+	return task, nil
+}
+
+// icalDate returns the local calendar day of a DATE or DATE-TIME value, or
+// "" when it cannot be read
+func icalDate(p icalProperty) string {
+	if d, err := time.ParseInLocation(icalDateFormat, p.value, time.Local); err == nil {
+		return domain.NewCalendarDate(d).String()
+	}
+	if t, ok := icalTime(p); ok {
+		return domain.NewCalendarDate(t.Local()).String()
+	}
+	return ""
+}
+
+// icalTime parses a DATE-TIME in UTC, in its TZID zone or floating
+func icalTime(p icalProperty) (time.Time, bool) {
+	if t, err := time.Parse(icalDateTimeFormat, p.value); err == nil {
+		return t, true
+	}
+	loc := time.Local
+	if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", p.value, loc)
+	return t, err == nil
+}
+
+// icalLines reads content lines, unfolding continuation lines
+func icalLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseICalLine splits a content line into its name, parameters and value
+func parseICalLine(line string) (icalProperty, error) {
+	// The value starts at the first colon outside a quoted parameter
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return icalProperty{}, fmt.Errorf("not a content line: %q", line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	prop := icalProperty{
+		name:   strings.ToUpper(parts[0]),
+		params: make(map[string]string),
+		value:  line[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			prop.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return prop, nil
+}
@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// ImportFormat is a file format tasks can be imported from
+type ImportFormat int
+
+const (
+	ImportMarkdown ImportFormat = iota
+	ImportICalendar
+	ImportTodoTxt
+	ImportTaskwarrior
+	ImportOrg
+)
+
+// importFormatNames maps format names, as given on the command line, to
+// formats
+var importFormatNames = map[string]ImportFormat{
+	"markdown":    ImportMarkdown,
+	"md":          ImportMarkdown,
+	"ical":        ImportICalendar,
+	"ics":         ImportICalendar,
+	"todotxt":     ImportTodoTxt,
+	"todo.txt":    ImportTodoTxt,
+	"taskwarrior": ImportTaskwarrior,
+	"tw":          ImportTaskwarrior,
+	"org":         ImportOrg,
+}
+
+// ParseImportFormat parses an import format name such as "markdown"
+func ParseImportFormat(name string) (ImportFormat, error) {
+	if f, ok := importFormatNames[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("unknown import format %q (want markdown, ical, todotxt, taskwarrior or org)", name)
+}
+
+// ImportFormatForPath guesses the import format from a file extension
+func ImportFormatForPath(path string) (ImportFormat, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return ImportMarkdown, true
+	case ".ics", ".ical":
+		return ImportICalendar, true
+	case ".txt":
+		return ImportTodoTxt, true
+	case ".json":
+		return ImportTaskwarrior, true
+	case ".org":
+		return ImportOrg, true
+	}
+	return 0, false
+}
+
+func (f ImportFormat) String() string {
+	switch f {
+	case ImportICalendar:
+		return "ical"
+	case ImportTodoTxt:
+		return "todotxt"
+	case ImportTaskwarrior:
+		return "taskwarrior"
+	case ImportOrg:
+		return "org"
+	default:
+		return "markdown"
+	}
+}
+
+// ImportData holds tasks read from an import file before they are merged
+// into the stored data. Tasks are trees with their Children filled in. A
+// root task with a ParentID belongs under that stored task.
+type ImportData struct {
+	Tasks []*domain.Task
+	// Events are notes about the imported tasks, logged on their day
+	Events []*domain.TimelineEvent
+	// Warnings describe what the file held that could not be imported
+	Warnings []string
+}
+
+// Import parses r in the given format. Tasks that the file does not place
+// on a day go on date.
+func (s *Storage) Import(r io.Reader, format ImportFormat, date string) (*ImportData, error) {
+	var tasks []*domain.Task
+	var err error
+	switch format {
+	case ImportTaskwarrior:
+		return parseTaskwarrior(r, date)
+	case ImportOrg:
+		return parseOrg(r, date)
+	case ImportICalendar:
+		tasks, err = parseICalendar(r, date)
+	case ImportTodoTxt:
+		tasks, err = parseTodoTxt(r, date)
+	default:
+		tasks, err = parseMarkdown(r, date)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ImportData{Tasks: tasks}, nil
+}
+
+// nestTasks builds trees from tasks whose ParentID names their parent.
+// Tasks whose parent is not among them stay roots and keep the ParentID, so
+// the merge can find the parent among the stored tasks. A ParentID that
+// would make a cycle is dropped.
+func nestTasks(tasks []*domain.Task) []*domain.Task {
+	byID := make(map[string]*domain.Task, len(tasks))
+	parents := make(map[string]string, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+		parents[task.ID] = task.ParentID
+		task.ParentID = ""
+	}
+
+	var roots []*domain.Task
+	for _, task := range tasks {
+		parent := byID[parents[task.ID]]
+		if parent == nil {
+			task.ParentID = parents[task.ID]
+			roots = append(roots, task)
+			continue
+		}
+		if isAncestor(task, parent, byID) {
+			roots = append(roots, task)
+			continue
+		}
+		parent.AddChild(task)
+	}
+	// AddChild copies the date down, but only onto what was attached so far
+	for _, root := range roots {
+		setSubtreeDate(root, root.Date)
+	}
+	return roots
+}
+
+// isAncestor reports whether task is parent or one of parent's ancestors
+func isAncestor(task, parent *domain.Task, byID map[string]*domain.Task) bool {
+	for p := parent; p != nil; p = byID[p.ParentID] {
+		if p == task {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportReport describes the outcome of MergeImport
+type ImportReport struct {
+	// Added lists every task added, parents before their children
+	Added []*domain.Task
+	// Updated lists stored tasks changed by an imported task with their ID
+	Updated []*domain.Task
+	// Duplicates lists imported tasks that matched a stored one and
+	// changed nothing. Their children are still merged into the stored task.
+	Duplicates []*domain.Task
+	// Events lists the notes added to the timeline
+	Events []*domain.TimelineEvent
+}
+
+// MergeImport adds copies of the imported tasks to tt, logging their
+// creation in tl, and leaves data unchanged so it can be merged again.
+//
+// An imported task with the ID of a stored task updates that task's title,
+// state, priority, due date and (for top-level tasks) day. Otherwise a task whose
+// title matches a task on the same day at the same level (ignoring case)
+// is a duplicate: it is skipped and its children are merged into the
+// existing task instead.
+//
+// Imported events go on the day of the task they are about, wherever it
+// ended up, unless the timeline already has the same note.
+func MergeImport(tt domain.TaskTree, tl domain.Timeline, data *ImportData) ImportReport {
+	var report ImportReport
+	// Where each imported task ended up
+	merged := make(map[string]*domain.Task)
+	mergeTasks(tt, tl, data.Tasks, nil, merged, &report)
+
+	for _, imported := range data.Events {
+		task := merged[imported.TaskID]
+		if task == nil || hasEvent(tl[task.Date], task.ID, imported) {
+			continue
+		}
+		event := *imported
+		event.TaskID, event.TaskTitle = task.ID, task.Title
+		tl.AddEvent(task.Date, &event)
+		report.Events = append(report.Events, &event)
+	}
+	return report
+}
+
+func mergeTasks(tt domain.TaskTree, tl domain.Timeline, tasks []*domain.Task, parent *domain.Task, merged map[string]*domain.Task, report *ImportReport) {
+	for _, imported := range tasks {
+		if existing := tt.FindTask(imported.ID); existing != nil {
+			if updateTask(tt, tl, existing, imported) {
+				report.Updated = append(report.Updated, existing)
+			} else {
+				report.Duplicates = append(report.Duplicates, imported)
+			}
+			merged[imported.ID] = existing
+			mergeTasks(tt, tl, imported.Children, existing, merged, report)
+			continue
+		}
+
+		into := parent
+		if into == nil && imported.ParentID != "" {
+			into = tt.FindTask(imported.ParentID)
+		}
+		siblings := tt[imported.Date]
+		if into != nil {
+			siblings = into.Children
+		}
+		if existing := findByTitle(siblings, imported.Title); existing != nil {
+			report.Duplicates = append(report.Duplicates, imported)
+			merged[imported.ID] = existing
+			mergeTasks(tt, tl, imported.Children, existing, merged, report)
+			continue
+		}
+
+		task := *imported
+		task.ParentID = ""
+		task.Children = make([]*domain.Task, 0, len(imported.Children))
+		domain.AddNewTask(tt, tl, &task, into)
+		report.Added = append(report.Added, &task)
+		merged[imported.ID] = &task
+		mergeTasks(tt, tl, imported.Children, &task, merged, report)
+	}
+}
+
+// updateTask copies the fields an import carries onto the stored task with
+// the same ID and reports whether anything changed. Subtasks stay under
+// their parent, so only top-level tasks move to another day.
+func updateTask(tt domain.TaskTree, tl domain.Timeline, task, imported *domain.Task) bool {
+	changed := false
+	if task.Date != imported.Date && task.ParentID == "" {
+		tt.RemoveTask(task.Date, task.ID)
+		setSubtreeDate(task, imported.Date)
+		tt.AddTask(task)
+		changed = true
+	}
+	if task.Title != imported.Title {
+		task.Title = imported.Title
+		changed = true
+	}
+	if !slices.Equal(task.Tags, imported.Tags) {
+		task.Tags = imported.Tags
+		changed = true
+	}
+	if task.Priority != imported.Priority {
+		task.SetPriority(imported.Priority)
+		changed = true
+	}
+	if task.DueDate != imported.DueDate {
+		task.DueDate = imported.DueDate
+		changed = true
+	}
+	if task.State != imported.State {
+		// Logged on the task's new day
+		domain.ChangeTaskState(tl, task, imported.State)
+		if imported.EndTime != nil {
+			task.EndTime = imported.EndTime
+		}
+		changed = true
+	}
+	if changed {
+		task.UpdatedAt = time.Now()
+	}
+	return changed
+}
+
+// setSubtreeDate moves a task and all its subtasks to date
+func setSubtreeDate(task *domain.Task, date string) {
+	task.Date = date
+	for _, child := range task.Children {
+		setSubtreeDate(child, date)
+	}
+}
+
+// hasEvent reports whether events already hold a note like event about
+// the task with the given ID
+func hasEvent(events []*domain.TimelineEvent, taskID string, event *domain.TimelineEvent) bool {
+	for _, e := range events {
+		if e.TaskID == taskID && e.Type == event.Type && e.Note == event.Note && e.Timestamp.Equal(event.Timestamp) {
+			return true
+		}
+	}
+	return false
+}
+
+// findByTitle returns the task in tasks with the given title, ignoring case
+func findByTitle(tasks []*domain.Task, title string) *domain.Task {
+	title = strings.TrimSpace(title)
+	for _, task := range tasks {
+		if strings.EqualFold(strings.TrimSpace(task.Title), title) {
+			return task
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// jsonBackend keeps everything in a single JSON file. Every change
+// rewrites the whole file.
+type jsonBackend struct {
+	path string
+}
+
+func (b *jsonBackend) Kind() BackendKind {
+	return BackendJSON
+}
+
+// Load reads the data file and returns the stored data
+func (b *jsonBackend) Load() (*StorageSchema, error) {
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Return default schema
+			return defaultSchema(), nil
+		}
+		return nil, err
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, newParseError(b.path, data, err)
+	}
+	if doc == nil {
+		doc = make(map[string]json.RawMessage)
+	}
+
+	// Bring older files up to the current schema. The file on disk is
+	// only rewritten (after a backup) by the next Save.
+	migrated, err := migrate(doc)
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	var schema StorageSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, newParseError(b.path, data, err)
+	}
+
+	// Hydrate dates (JSON stores as strings, need to parse)
+	hydrateDates(&schema)
+
+	// Files edited by hand may omit either section
+	if schema.Tasks == nil {
+		schema.Tasks = make(domain.TaskTree)
+	}
+	if schema.Timeline == nil {
+		schema.Timeline = make(domain.Timeline)
+	}
+
+	return &schema, nil
+}
+
+// Save writes the data to disk atomically
+func (b *jsonBackend) Save(schema *StorageSchema) error {
+	// Never downgrade a newer file, and keep a copy of an older one before
+	// it is rewritten in the current format
+	if version, ok := b.diskVersion(); ok {
+		cmp, err := compareVersions(version, CurrentVersion)
+		if err != nil {
+			return fmt.Errorf("%s: %w", b.path, err)
+		}
+		if cmp > 0 {
+			return &VersionError{Path: b.path, Version: version}
+		}
+		if cmp < 0 {
+			if err := backupFile(b.path); err != nil {
+				return fmt.Errorf("backing up before schema upgrade: %w", err)
+			}
+		}
+	}
+	schema.Version = CurrentVersion
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(b.path, data, 0644)
+}
+
+// diskVersion returns the schema version of the file currently on disk,
+// and false when there is no readable file to compare against
+func (b *jsonBackend) diskVersion() (string, bool) {
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		return "", false
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", false
+	}
+	version, err := docVersion(doc)
+	if err != nil {
+		return "", false
+	}
+	return version, true
+}
+
+// GetTask returns a task with its subtasks
+func (b *jsonBackend) GetTask(id string) (*domain.Task, error) {
+	schema, err := b.Load()
+	if err != nil {
+		return nil, err
+	}
+	task := schema.Tasks.FindTask(id)
+	if task == nil {
+		return nil, ErrTaskNotFound
+	}
+	return task, nil
+}
+
+// PutTask creates or replaces a task and its subtasks
+func (b *jsonBackend) PutTask(task *domain.Task) error {
+	schema, err := b.Load()
+	if err != nil {
+		return err
+	}
+	if err := putTask(schema.Tasks, task); err != nil {
+		return err
+	}
+	return b.Save(schema)
+}
+
+// DeleteTask removes a task and its subtasks
+func (b *jsonBackend) DeleteTask(id string) error {
+	schema, err := b.Load()
+	if err != nil {
+		return err
+	}
+	task := schema.Tasks.FindTask(id)
+	if task == nil {
+		return ErrTaskNotFound
+	}
+	schema.Tasks.RemoveTask(task.Date, id)
+	return b.Save(schema)
+}
+
+// AppendEvent adds an event to the timeline of date
+func (b *jsonBackend) AppendEvent(date string, event *domain.TimelineEvent) error {
+	schema, err := b.Load()
+	if err != nil {
+		return err
+	}
+	schema.Timeline.AddEvent(date, event)
+	return b.Save(schema)
+}
+
+// putTask stores task in tt, in place when it already sits under the same
+// parent and otherwise at the end of its new parent or day
+func putTask(tt domain.TaskTree, task *domain.Task) error {
+	var parent *domain.Task
+	if task.ParentID != "" {
+		if parent = tt.FindTask(task.ParentID); parent == nil {
+			return fmt.Errorf("parent %s: %w", task.ParentID, ErrTaskNotFound)
+		}
+	}
+
+	if existing := tt.FindTask(task.ID); existing != nil {
+		if existing.ParentID == task.ParentID && existing.Date == task.Date && tt.ReplaceTask(task) {
+			return nil
+		}
+		tt.RemoveTask(existing.Date, task.ID)
+	}
+
+	if parent != nil {
+		parent.Children = append(parent.Children, task)
+	} else {
+		tt.AddTask(task)
+	}
+	return nil
+}
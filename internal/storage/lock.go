@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lock is an advisory single-writer lock on the data file. Any process that
+// saves data.json must hold it; the TUI keeps it for its whole lifetime.
+type Lock struct {
+	file *os.File
+}
+
+// LockedError is returned when another process holds the data file lock
+type LockedError struct {
+	Path string
+	PID  int // Zero when the holder's PID could not be read
+}
+
+func (e *LockedError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("%s is locked by another seyal process (pid %d)", e.Path, e.PID)
+	}
+	return fmt.Sprintf("%s is locked by another seyal process", e.Path)
+}
+
+// errWouldBlock is returned by the platform lock functions when the lock is held
+var errWouldBlock = errors.New("lock is held by another process")
+
+// lockPath returns the path of the lock file next to the data file
+func (s *Storage) lockPath() string {
+	return s.DataPath + ".lock"
+}
+
+// Lock acquires the data file lock without waiting. It returns a
+// *LockedError naming the holder when another process has it.
+func (s *Storage) Lock() (*Lock, error) {
+	f, err := os.OpenFile(s.lockPath(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, errWouldBlock) {
+			return nil, &LockedError{Path: s.DataPath, PID: readLockPID(s.lockPath())}
+		}
+		return nil, err
+	}
+
+	// Record our PID so a second instance can name the holder
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{file: f}, nil
+}
+
+// LockWait acquires the data file lock, retrying until timeout while another
+// process holds it
+func (s *Storage) LockWait(timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		lock, err := s.Lock()
+		var locked *LockedError
+		if !errors.As(err, &locked) || time.Now().After(deadline) {
+			return lock, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Unlock releases the lock
+func (l *Lock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	unlockFile(l.file)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Update runs fn on freshly loaded data and saves the result while holding
+// the lock, so concurrent writers cannot overwrite each other. It returns a
+// *LockedError if another process keeps the lock for more than a moment.
+func (s *Storage) Update(fn func(schema *StorageSchema) error) error {
+	return s.update(func() (*Lock, error) { return s.LockWait(2 * time.Second) }, fn)
+}
+
+// TryUpdate is Update without the wait: it returns a *LockedError at once
+// when another process holds the lock.
+func (s *Storage) TryUpdate(fn func(schema *StorageSchema) error) error {
+	return s.update(s.Lock, fn)
+}
+
+func (s *Storage) update(acquire func() (*Lock, error), fn func(schema *StorageSchema) error) error {
+	lock, err := acquire()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	schema, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if err := fn(schema); err != nil {
+		return err
+	}
+	return s.Save(schema)
+}
+
+func readLockPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
@@ -0,0 +1,19 @@
+//go:build !unix && !windows
+
+package storage
+
+import "os"
+
+// Platforms without file locking fall back to no locking
+
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
+
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+var (
+	// "## 2024-03-01" starts a day, as written by exportMarkdown
+	markdownDayHeading = regexp.MustCompile(`^##\s+(\d{4}-\d{2}-\d{2})\s*$`)
+	// "  - [x] Title **P1**" is a task; the indent nests it
+	markdownTaskItem = regexp.MustCompile(`^([ \t]*)[-*+]\s+\[([ xX])\]\s+(.*)$`)
+	// The priority suffix written by taskToMarkdown. Plain "P1" and "P2"
+	// are accepted too since hand-written lists rarely bother with emphasis.
+	markdownPriority = regexp.MustCompile(`\s+(\*{0,2})P([123])(\*{0,2})$`)
+)
+
+// parseMarkdown reads a checklist in the shape exportMarkdown writes: days
+// as "## YYYY-MM-DD" headings followed by "- [ ]" and "- [x]" items, nested
+// by indentation, with "#tag" words as tags. Items before the first heading
+// go on date. Anything else in the file is ignored.
+func parseMarkdown(r io.Reader, date string) ([]*domain.Task, error) {
+	type level struct {
+		indent int
+		task   *domain.Task
+	}
+
+	var tasks []*domain.Task
+	var stack []level
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		if m := markdownDayHeading.FindStringSubmatch(line); m != nil {
+			if _, err := domain.ParseCalendarDate(m[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			date = m[1]
+			stack = stack[:0]
+			continue
+		}
+
+		m := markdownTaskItem.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		title, priority := markdownTitle(m[3])
+		if title == "" {
+			continue
+		}
+		title, tags := domain.ParseTags(title)
+
+		task := domain.NewTask(title, date)
+		task.Tags = tags
+		if m[2] != " " {
+			task.SetState(domain.TaskStateCompleted)
+		}
+		if priority != domain.PriorityNone {
+			task.SetPriority(priority)
+		}
+
+		indent := indentWidth(m[1])
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			stack[len(stack)-1].task.AddChild(task)
+		} else {
+			tasks = append(tasks, task)
+		}
+		stack = append(stack, level{indent, task})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// markdownTitle splits the priority suffix off an item's text
+func markdownTitle(text string) (string, domain.TaskPriority) {
+	text = strings.TrimSpace(text)
+	m := markdownPriority.FindStringSubmatchIndex(text)
+	if m == nil {
+		return text, domain.PriorityNone
+	}
+	open, close := text[m[2]:m[3]], text[m[6]:m[7]]
+	if open != close {
+		return text, domain.PriorityNone // "P1*" is part of the title
+	}
+	priority := domain.TaskPriority(text[m[4]] - '0')
+	return strings.TrimSpace(text[:m[0]]), priority
+}
+
+// indentWidth measures leading whitespace, counting a tab as four spaces
+func indentWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if r == '\t' {
+			width += 4
+		} else {
+			width++
+		}
+	}
+	return width
+}
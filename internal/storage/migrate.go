@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CurrentVersion is the schema version this build reads and writes
+const CurrentVersion = "1.3.0"
+
+// migration upgrades a raw data file from one schema version to the next
+type migration struct {
+	from  string
+	to    string
+	apply func(doc map[string]json.RawMessage) error
+}
+
+// migrations run in order, each starting where the previous one ended.
+// Add a step here (and bump CurrentVersion) whenever the file format
+// changes in a way older files need fixing up for.
+var migrations = []migration{
+	{from: "", to: "1.0.0", apply: migrateUnversioned},
+	{from: "1.0.0", to: "1.1.0", apply: addFields}, // Task tags
+	{from: "1.1.0", to: "1.2.0", apply: addFields}, // Recurrences, task seriesId and occurrence
+	{from: "1.2.0", to: "1.3.0", apply: addFields}, // Task dueDate
+}
+
+// migrateUnversioned fills in the sections that files written before
+// versioning (or by hand) may lack
+func migrateUnversioned(doc map[string]json.RawMessage) error {
+	for _, key := range []string{"tasks", "timeline"} {
+		if raw, ok := doc[key]; !ok || string(raw) == "null" {
+			doc[key] = json.RawMessage("{}")
+		}
+	}
+	return nil
+}
+
+// addFields is the step for a version that only adds optional fields.
+// Older files need nothing, but the new version keeps older seyals, which
+// would drop the fields on save, from writing the file.
+func addFields(doc map[string]json.RawMessage) error {
+	return nil
+}
+
+// VersionError reports a data file written by a newer seyal than this one
+type VersionError struct {
+	Path    string
+	Version string
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("%s uses schema %s, newer than this seyal understands (%s); upgrade seyal to change it",
+		e.Path, e.Version, CurrentVersion)
+}
+
+// IsNewer reports whether the schema was written by a newer seyal and must
+// not be saved by this one
+func (schema *StorageSchema) IsNewer() bool {
+	cmp, err := compareVersions(schema.Version, CurrentVersion)
+	return err == nil && cmp > 0
+}
+
+// migrate brings a decoded data file up to CurrentVersion in place and
+// reports whether anything changed. Files from a newer schema are left
+// alone.
+func migrate(doc map[string]json.RawMessage) (bool, error) {
+	version, err := docVersion(doc)
+	if err != nil {
+		return false, err
+	}
+
+	cmp, err := compareVersions(version, CurrentVersion)
+	if err != nil {
+		return false, err
+	}
+	if cmp >= 0 {
+		return false, nil
+	}
+
+	for version != CurrentVersion {
+		step := findMigration(version)
+		if step == nil {
+			return false, fmt.Errorf("no migration from schema %q to %s", version, CurrentVersion)
+		}
+		if err := step.apply(doc); err != nil {
+			return false, fmt.Errorf("migrating schema %q to %s: %w", version, step.to, err)
+		}
+		version = step.to
+	}
+
+	doc["version"], _ = json.Marshal(version)
+	return true, nil
+}
+
+func findMigration(from string) *migration {
+	for i := range migrations {
+		if migrations[i].from == from {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
+
+// docVersion reads the version field, treating a missing one as ""
+func docVersion(doc map[string]json.RawMessage) (string, error) {
+	raw, ok := doc["version"]
+	if !ok {
+		return "", nil
+	}
+	var version string
+	if err := json.Unmarshal(raw, &version); err != nil {
+		return "", fmt.Errorf("invalid schema version %s", raw)
+	}
+	return version, nil
+}
+
+// compareVersions compares two major.minor.patch versions. The empty
+// version of unversioned files sorts before all others.
+func compareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			if va[i] < vb[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func parseVersion(v string) ([3]int, error) {
+	var parts [3]int
+	if v == "" {
+		return parts, nil
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) > 3 {
+		return parts, fmt.Errorf("invalid schema version %q", v)
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, fmt.Errorf("invalid schema version %q", v)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// Org-mode support. Days are level-one headings and tasks are headings
+// below them, nested one level per subtask:
+//
+//	* 2026-01-02
+//	** TODO [#A] Title
+//	SCHEDULED: <2026-01-02 Fri> DEADLINE: <2026-01-05 Mon>
+//	:PROPERTIES:
+//	:ID:       <uuid>
+//	:PUSHED:   1
+//	:END:
+//	CLOCK: [2026-01-02 Fri 09:00]--[2026-01-02 Fri 10:30] =>  1:30
+//
+// The #+TODO line tells Emacs about the WAITING (delegated) and DEFERRED
+// (delayed) keywords.
+
+const (
+	orgDateFormat = "2006-01-02 Mon"
+	orgTimeFormat = "2006-01-02 Mon 15:04"
+)
+
+// orgKeywords maps task states to Org TODO keywords
+var orgKeywords = map[domain.TaskState]string{
+	domain.TaskStateTodo:      "TODO",
+	domain.TaskStateCompleted: "DONE",
+	domain.TaskStateDelegated: "WAITING",
+	domain.TaskStateDelayed:   "DEFERRED",
+}
+
+// exportOrg writes the tasks of the days in dates as an Org file
+func (s *Storage) exportOrg(tasks domain.TaskTree, dates domain.DateRange) (string, error) {
+	var b strings.Builder
+	b.WriteString("#+TITLE: seyal\n")
+	b.WriteString("#+TODO: TODO WAITING DEFERRED | DONE\n")
+	for _, date := range exportDates(tasks, dates) {
+		b.WriteString("\n* " + date + "\n")
+		for _, task := range tasks[date] {
+			writeOrgTask(&b, task, 2)
+		}
+	}
+	return b.String(), nil
+}
+
+func writeOrgTask(b *strings.Builder, task *domain.Task, level int) {
+	heading := strings.Repeat("*", level) + " " + orgKeywords[task.State]
+	if p := todoTxtPriority(task.Priority); p != "" {
+		heading += " [#" + p + "]"
+	}
+	// A line break would end the heading
+	heading += " " + strings.Join(strings.Fields(task.Title), " ")
+	if len(task.Tags) > 0 {
+		heading += " :" + strings.Join(task.Tags, ":") + ":"
+	}
+	b.WriteString(heading + "\n")
+
+	var planning []string
+	if task.State == domain.TaskStateCompleted {
+		closed := task.UpdatedAt
+		if task.EndTime != nil {
+			closed = *task.EndTime
+		}
+		planning = append(planning, "CLOSED: ["+closed.Local().Format(orgTimeFormat)+"]")
+	}
+	if day, err := domain.ParseCalendarDate(task.Date); err == nil {
+		planning = append(planning, "SCHEDULED: <"+day.Time().Format(orgDateFormat)+">")
+	}
+	if due, err := domain.ParseCalendarDate(task.DueDate); err == nil {
+		planning = append(planning, "DEADLINE: <"+due.Time().Format(orgDateFormat)+">")
+	}
+	if len(planning) > 0 {
+		b.WriteString(strings.Join(planning, " ") + "\n")
+	}
+
+	b.WriteString(":PROPERTIES:\n")
+	fmt.Fprintf(b, ":ID:       %s\n", task.ID)
+	if task.PushedCount > 0 {
+		fmt.Fprintf(b, ":PUSHED:   %d\n", task.PushedCount)
+	}
+	b.WriteString(":END:\n")
+
+	if task.StartTime != nil {
+		clock := "CLOCK: [" + task.StartTime.Local().Format(orgTimeFormat) + "]"
+		if d, ok := trackedTime(task); ok {
+			minutes := int(d.Round(time.Minute).Minutes())
+			clock += fmt.Sprintf("--[%s] => %2d:%02d", task.EndTime.Local().Format(orgTimeFormat), minutes/60, minutes%60)
+		}
+		b.WriteString(clock + "\n")
+	}
+
+	for _, child := range task.Children {
+		writeOrgTask(b, child, level+1)
+	}
+}
+
+var (
+	// "** TODO [#A] Title :tag:" is a heading; the stars give its level
+	orgHeading = regexp.MustCompile(`^(\*+)\s+(.*?)\s*$`)
+	// "* 2026-01-02" starts a day, as written by exportOrg. A weekday
+	// after the date is accepted too.
+	orgDayHeading = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})(?:\s+\w+)?$`)
+	orgPriority   = regexp.MustCompile(`^\[#([A-Z])\]\s*`)
+	orgTags       = regexp.MustCompile(`\s+:([\p{L}\p{N}_@#%:/-]+):$`)
+	orgProperty   = regexp.MustCompile(`^:([\w-]+):\s*(.*?)\s*$`)
+	// A timestamp such as <2026-01-02 Fri> or [2026-01-02 Fri 09:00]
+	orgTimestamp = regexp.MustCompile(`[<\[](\d{4}-\d{2}-\d{2})(?:\s+[^\s\]>\d][^\s\]>]*)?(?:\s+(\d{1,2}:\d{2}))?[^\]>]*[\]>]`)
+	orgScheduled = regexp.MustCompile(`\bSCHEDULED:\s*(<[^>]*>)`)
+	orgDeadline  = regexp.MustCompile(`\bDEADLINE:\s*(<[^>]*>)`)
+	orgClosed    = regexp.MustCompile(`\bCLOSED:\s*(\[[^\]]*\])`)
+	orgClock     = regexp.MustCompile(`^CLOCK:\s*(\[[^\]]*\])(?:--(\[[^\]]*\]))?`)
+)
+
+// parseOrg reads an Org file in the shape exportOrg writes. Below a
+// "* YYYY-MM-DD" heading every heading is a task. Elsewhere only headings
+// with a TODO keyword are, and they go on date unless they are scheduled.
+// Body text is ignored.
+func parseOrg(r io.Reader, date string) (*ImportData, error) {
+	type level struct {
+		stars int
+		task  *domain.Task // nil for headings that are not tasks
+	}
+
+	data := &ImportData{}
+	var tasks []*domain.Task
+	var stack []level
+	parents := make(map[*domain.Task]*domain.Task)
+	defaultDate, inDay := date, false
+	var current *domain.Task
+	inProperties := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		if m := orgHeading.FindStringSubmatch(line); m != nil {
+			stars, text := len(m[1]), m[2]
+			inProperties = false
+			current = nil
+			if stars == 1 {
+				stack = stack[:0]
+				if d := orgDayHeading.FindStringSubmatch(text); d != nil {
+					if _, err := domain.ParseCalendarDate(d[1]); err != nil {
+						return nil, fmt.Errorf("line %d: %w", lineNo, err)
+					}
+					date, inDay = d[1], true
+					continue
+				}
+				date, inDay = defaultDate, false
+			}
+			for len(stack) > 0 && stack[len(stack)-1].stars >= stars {
+				stack = stack[:len(stack)-1]
+			}
+
+			task := orgTask(text, date)
+			if task != nil && (inDay || task.State != "" || task.Priority != domain.PriorityNone) {
+				if task.State == "" {
+					task.State = domain.TaskStateTodo
+				}
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i].task != nil {
+						parents[task] = stack[i].task
+						break
+					}
+				}
+				tasks = append(tasks, task)
+				current = task
+			} else {
+				task = nil
+			}
+			stack = append(stack, level{stars, task})
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		text := strings.TrimSpace(line)
+		switch {
+		case text == ":PROPERTIES:":
+			inProperties = true
+		case inProperties && strings.EqualFold(text, ":END:"):
+			inProperties = false
+		case inProperties:
+			if m := orgProperty.FindStringSubmatch(text); m != nil {
+				switch strings.ToUpper(m[1]) {
+				case "ID":
+					if m[2] != "" {
+						current.ID = m[2]
+					}
+				case "PUSHED":
+					if n, err := strconv.Atoi(m[2]); err == nil && n >= 0 {
+						current.PushedCount = n
+					}
+				}
+			}
+		case orgClock.MatchString(text):
+			m := orgClock.FindStringSubmatch(text)
+			start, ok := orgTime(m[1])
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid clock %q", lineNo, text)
+			}
+			end, closed := orgTime(m[2])
+			addOrgClock(current, start, end, closed)
+		default:
+			if m := orgScheduled.FindStringSubmatch(text); m != nil {
+				if t, ok := orgTime(m[1]); ok {
+					current.Date = domain.NewCalendarDate(t).String()
+				}
+			}
+			if m := orgDeadline.FindStringSubmatch(text); m != nil {
+				if t, ok := orgTime(m[1]); ok {
+					current.DueDate = domain.NewCalendarDate(t).String()
+				}
+			}
+			if m := orgClosed.FindStringSubmatch(text); m != nil && current.EndTime == nil {
+				if t, ok := orgTime(m[1]); ok && current.State == domain.TaskStateCompleted {
+					current.EndTime = &t
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// IDs come from the property drawers, after the headings
+	seen := make(map[string]bool)
+	unique := tasks[:0]
+	for _, task := range tasks {
+		if seen[task.ID] {
+			continue
+		}
+		seen[task.ID] = true
+		if parent := parents[task]; parent != nil {
+			task.ParentID = parent.ID
+		}
+		if task.EndTime != nil && task.UpdatedAt.Before(*task.EndTime) {
+			task.UpdatedAt = *task.EndTime
+		}
+		unique = append(unique, task)
+	}
+	data.Tasks = nestTasks(unique)
+	return data, nil
+}
+
+// orgTask parses a heading's text after the stars. The state is left empty
+// when the heading has no TODO keyword. It returns nil for empty headings.
+func orgTask(text, date string) *domain.Task {
+	task := domain.NewTask("", date)
+	task.State = ""
+
+	keyword, rest, _ := strings.Cut(text, " ")
+	for state, kw := range orgKeywords {
+		if keyword == kw {
+			task.State = state
+			text = strings.TrimSpace(rest)
+			break
+		}
+	}
+	if m := orgPriority.FindStringSubmatch(text); m != nil {
+		task.Priority = taskPriorityFromLetter(m[1])
+		text = text[len(m[0]):]
+	}
+	if m := orgTags.FindStringSubmatchIndex(text); m != nil {
+		for _, tag := range strings.Split(text[m[2]:m[3]], ":") {
+			task.Tags = domain.AddTag(task.Tags, tag)
+		}
+		text = text[:m[0]]
+	}
+
+	task.Title = strings.TrimSpace(text)
+	if task.Title == "" {
+		if task.State == "" {
+			return nil
+		}
+		task.Title = "Untitled task"
+	}
+	return task
+}
+
+// addOrgClock folds a CLOCK line into the task's single start and end
+// time: the earliest start and the latest end, unless a clock is still
+// running, in which case the task is too
+func addOrgClock(task *domain.Task, start, end time.Time, closed bool) {
+	if task.IsRunning() {
+		return
+	}
+	if !closed {
+		task.StartTime, task.EndTime = &start, nil
+		return
+	}
+	if task.StartTime == nil || start.Before(*task.StartTime) {
+		task.StartTime = &start
+	}
+	if task.EndTime == nil || end.After(*task.EndTime) {
+		task.EndTime = &end
+	}
+}
+
+// orgTime parses an Org timestamp in local time. Timestamps without a
+// time of day are local midnight.
+func orgTime(s string) (time.Time, bool) {
+	m := orgTimestamp.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, false
+	}
+	value, layout := m[1], "2006-01-02"
+	if m[2] != "" {
+		value, layout = m[1]+" "+m[2], "2006-01-02 15:04"
+	}
+	t, err := time.ParseInLocation(layout, value, time.Local)
+	return t, err == nil
+}
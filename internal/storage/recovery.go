@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ParseError reports a data file that exists but is not valid seyal JSON
+type ParseError struct {
+	Path    string
+	Line    int    // 1-based
+	Column  int    // 1-based
+	Snippet string // The offending line
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %v", e.Path, e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError locates a JSON decoding error in data
+func newParseError(path string, data []byte, err error) *ParseError {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// Offset counts the byte that caused the error
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	offset = min(max(offset, 0), int64(len(data)))
+
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	lineEnd := bytes.IndexByte(data[lineStart:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(data) - lineStart
+	}
+
+	return &ParseError{
+		Path:    path,
+		Line:    line,
+		Column:  int(offset) - lineStart + 1,
+		Snippet: string(data[lineStart : lineStart+lineEnd]),
+		Err:     err,
+	}
+}
+
+// Quarantine moves an unreadable data file aside so nothing can overwrite
+// it, and returns its new path
+func (s *Storage) Quarantine() (string, error) {
+	path := s.DataPath + ".corrupt-" + time.Now().Format("20060102-150405")
+	if err := os.Rename(s.DataPath, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Backups returns the paths of all backup files, newest first
+func (s *Storage) Backups() ([]string, error) {
+	paths, err := filepath.Glob(s.DataPath + ".backup-*")
+	if err != nil {
+		return nil, err
+	}
+	// Timestamps in the file names sort chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	return paths, nil
+}
+
+// RestoreBackup replaces the data file with the backup at path after
+// checking that the backup itself loads
+func (s *Storage) RestoreBackup(path string) error {
+	if _, err := newBackend(s.Kind(), path).Load(); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.DataPath, data, 0644)
+}
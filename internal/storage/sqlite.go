@@ -0,0 +1,813 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+	_ "modernc.org/sqlite" // Pure-Go driver, registers "sqlite"
+)
+
+// sqliteBackend keeps tasks, timeline events, recurrences and settings in a SQLite
+// database. Save only writes the rows that changed, and the task and
+// timeline methods touch single rows.
+type sqliteBackend struct {
+	path string
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS settings (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL -- JSON encoded
+);
+CREATE TABLE IF NOT EXISTS tasks (
+	id           TEXT PRIMARY KEY,
+	parent_id    TEXT REFERENCES tasks(id) ON DELETE CASCADE,
+	position     INTEGER NOT NULL, -- Order within the parent, or the day for top-level tasks
+	date         TEXT NOT NULL,
+	title        TEXT NOT NULL,
+	state        TEXT NOT NULL,
+	priority     INTEGER NOT NULL,
+	created_at   TEXT NOT NULL,
+	updated_at   TEXT NOT NULL,
+	start_time   TEXT,
+	end_time     TEXT,
+	pushed_count INTEGER NOT NULL DEFAULT 0,
+	tags         TEXT NOT NULL DEFAULT '', -- Space separated
+	series_id    TEXT NOT NULL DEFAULT '',
+	occurrence   TEXT NOT NULL DEFAULT '',
+	due_date     TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS tasks_date ON tasks(date);
+CREATE INDEX IF NOT EXISTS tasks_parent ON tasks(parent_id);
+CREATE TABLE IF NOT EXISTS timeline_events (
+	id             TEXT PRIMARY KEY,
+	date           TEXT NOT NULL,
+	position       INTEGER NOT NULL,
+	task_id        TEXT NOT NULL,
+	task_title     TEXT NOT NULL,
+	type           TEXT NOT NULL,
+	timestamp      TEXT NOT NULL,
+	previous_state TEXT NOT NULL DEFAULT '',
+	new_state      TEXT NOT NULL DEFAULT '',
+	note           TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS timeline_events_date ON timeline_events(date);
+CREATE TABLE IF NOT EXISTS recurrences (
+	id       TEXT PRIMARY KEY,
+	position INTEGER NOT NULL,
+	rule     TEXT NOT NULL, -- RRULE text
+	start    TEXT NOT NULL,
+	through  TEXT NOT NULL,
+	template TEXT NOT NULL -- JSON encoded task
+);
+`
+
+const taskColumns = `id, parent_id, position, date, title, state, priority,
+	created_at, updated_at, start_time, end_time, pushed_count, tags,
+	series_id, occurrence, due_date`
+
+const eventColumns = `id, date, position, task_id, task_title, type,
+	timestamp, previous_state, new_state, note`
+
+// taskRecord is one row of the tasks table
+type taskRecord struct {
+	ID          string
+	ParentID    sql.NullString
+	Position    int
+	Date        string
+	Title       string
+	State       string
+	Priority    int
+	CreatedAt   string
+	UpdatedAt   string
+	StartTime   sql.NullString
+	EndTime     sql.NullString
+	PushedCount int
+	Tags        string
+	SeriesID    string
+	Occurrence  string
+	DueDate     string
+}
+
+// eventRecord is one row of the timeline_events table
+type eventRecord struct {
+	ID            string
+	Date          string
+	Position      int
+	TaskID        string
+	TaskTitle     string
+	Type          string
+	Timestamp     string
+	PreviousState string
+	NewState      string
+	Note          string
+}
+
+// recurrenceRecord is one row of the recurrences table
+type recurrenceRecord struct {
+	ID       string
+	Position int
+	Rule     string
+	Start    string
+	Through  string
+	Template string
+}
+
+func (b *sqliteBackend) Kind() BackendKind {
+	return BackendSQLite
+}
+
+// open opens the database, creating the tables on first use. The
+// connection is closed after every operation so nothing stays open
+// between a program's saves.
+func (b *sqliteBackend) open() (*sql.DB, error) {
+	db, err := sql.Open("sqlite", b.path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", b.path, err)
+	}
+	if err := addMissingColumns(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", b.path, err)
+	}
+	return db, nil
+}
+
+// sqliteAddedColumns lists columns added to tables after they were first
+// created. CREATE TABLE IF NOT EXISTS leaves older databases without them.
+var sqliteAddedColumns = []struct {
+	table, column, definition string
+}{
+	{"timeline_events", "note", "TEXT NOT NULL DEFAULT ''"},
+	{"tasks", "tags", "TEXT NOT NULL DEFAULT ''"},
+	{"tasks", "series_id", "TEXT NOT NULL DEFAULT ''"},
+	{"tasks", "occurrence", "TEXT NOT NULL DEFAULT ''"},
+	{"tasks", "due_date", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// addMissingColumns brings the tables of an older database up to date
+func addMissingColumns(db *sql.DB) error {
+	for _, c := range sqliteAddedColumns {
+		var count int
+		err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE ` + c.table + ` ADD COLUMN ` + c.column + ` ` + c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load reads the whole database
+func (b *sqliteBackend) Load() (*StorageSchema, error) {
+	db, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	version, err := readVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		version = CurrentVersion // Nothing saved yet
+	}
+
+	schema := &StorageSchema{Version: version}
+	if schema.Settings, err = readSettings(db); err != nil {
+		return nil, err
+	}
+
+	tasks, err := queryTasks(db, `SELECT `+taskColumns+` FROM tasks ORDER BY position`)
+	if err != nil {
+		return nil, err
+	}
+	schema.Tasks = make(domain.TaskTree)
+	for _, task := range buildTree(tasks) {
+		schema.Tasks.AddTask(task)
+	}
+
+	events, err := queryEvents(db, `SELECT `+eventColumns+` FROM timeline_events ORDER BY date, position`)
+	if err != nil {
+		return nil, err
+	}
+	schema.Timeline = make(domain.Timeline)
+	for _, rec := range events {
+		event, err := rec.event()
+		if err != nil {
+			return nil, err
+		}
+		schema.Timeline.AddEvent(rec.Date, event)
+	}
+
+	recurrences, err := queryRecurrences(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range recurrences {
+		recurrence, err := rec.recurrence()
+		if err != nil {
+			return nil, err
+		}
+		schema.Recurrences = append(schema.Recurrences, recurrence)
+	}
+
+	return schema, nil
+}
+
+// Save brings the database in line with schema, writing only the rows
+// that differ
+func (b *sqliteBackend) Save(schema *StorageSchema) error {
+	db, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := prepareWrite(tx, b.path); err != nil {
+		return err
+	}
+	schema.Version = CurrentVersion
+	if err := writeSettings(tx, schema.Settings); err != nil {
+		return err
+	}
+
+	// Tasks: parents are listed before their children, so upserting in
+	// order never points at a missing parent. Rows that are gone are
+	// deleted afterwards, when no kept task refers to them any more.
+	var want []taskRecord
+	for _, date := range sortedKeys(schema.Tasks) {
+		for i, task := range schema.Tasks[date] {
+			want = appendTaskRecords(want, task, "", i)
+		}
+	}
+	have, err := queryTasks(tx, `SELECT `+taskColumns+` FROM tasks`)
+	if err != nil {
+		return err
+	}
+	if err := syncRows(tx, "tasks", want, have, func(r taskRecord) string { return r.ID }, upsertTask); err != nil {
+		return err
+	}
+
+	var wantEvents []eventRecord
+	for _, date := range sortedKeys(schema.Timeline) {
+		for i, event := range schema.Timeline[date] {
+			wantEvents = append(wantEvents, newEventRecord(date, i, event))
+		}
+	}
+	haveEvents, err := queryEvents(tx, `SELECT `+eventColumns+` FROM timeline_events`)
+	if err != nil {
+		return err
+	}
+	if err := syncRows(tx, "timeline_events", wantEvents, haveEvents, func(r eventRecord) string { return r.ID }, upsertEvent); err != nil {
+		return err
+	}
+
+	var wantRecurrences []recurrenceRecord
+	for i, recurrence := range schema.Recurrences {
+		rec, err := newRecurrenceRecord(i, recurrence)
+		if err != nil {
+			return err
+		}
+		wantRecurrences = append(wantRecurrences, rec)
+	}
+	haveRecurrences, err := queryRecurrences(tx)
+	if err != nil {
+		return err
+	}
+	if err := syncRows(tx, "recurrences", wantRecurrences, haveRecurrences, func(r recurrenceRecord) string { return r.ID }, upsertRecurrence); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetTask returns a task with its subtasks
+func (b *sqliteBackend) GetTask(id string) (*domain.Task, error) {
+	db, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	records, err := querySubtree(db, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrTaskNotFound
+	}
+	// The root's parent is not part of the subtree, so buildTree returns it
+	// as the only top-level task; put the reference back
+	task := buildTree(records)[0]
+	for _, r := range records {
+		if r.ID == id {
+			task.ParentID = r.ParentID.String
+		}
+	}
+	return task, nil
+}
+
+// PutTask creates or replaces a task and its subtasks. A task keeps its
+// position when its parent and date are unchanged.
+func (b *sqliteBackend) PutTask(task *domain.Task) error {
+	db, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := prepareWrite(tx, b.path); err != nil {
+		return err
+	}
+
+	parentID := sql.NullString{String: task.ParentID, Valid: task.ParentID != ""}
+	if parentID.Valid {
+		var n int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM tasks WHERE id = ?`, task.ParentID).Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("parent %s: %w", task.ParentID, ErrTaskNotFound)
+		}
+	}
+
+	// Keep the old position when the task stays where it was
+	position := -1
+	var oldParent sql.NullString
+	var oldPosition int
+	var oldDate string
+	err = tx.QueryRow(`SELECT parent_id, position, date FROM tasks WHERE id = ?`, task.ID).Scan(&oldParent, &oldPosition, &oldDate)
+	switch {
+	case err == nil && oldParent == parentID && (parentID.Valid || oldDate == task.Date):
+		position = oldPosition
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+	if position < 0 {
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(position) + 1, 0) FROM tasks
+			WHERE parent_id IS ? AND (parent_id IS NOT NULL OR date = ?)`, parentID, task.Date).Scan(&position); err != nil {
+			return err
+		}
+	}
+
+	records := appendTaskRecords(nil, task, task.ParentID, position)
+	existing, err := querySubtree(tx, task.ID)
+	if err != nil {
+		return err
+	}
+	// Only the old subtree is compared, so rows elsewhere are left alone
+	if err := syncRows(tx, "tasks", records, existing, func(r taskRecord) string { return r.ID }, upsertTask); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteTask removes a task; its subtasks go with it through the foreign key
+func (b *sqliteBackend) DeleteTask(id string) error {
+	db, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := prepareWrite(db, b.path); err != nil {
+		return err
+	}
+	res, err := db.Exec(`DELETE FROM tasks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+// AppendEvent adds an event after the last one of date
+func (b *sqliteBackend) AppendEvent(date string, event *domain.TimelineEvent) error {
+	db, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := prepareWrite(db, b.path); err != nil {
+		return err
+	}
+	var position int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(position) + 1, 0) FROM timeline_events WHERE date = ?`, date).Scan(&position); err != nil {
+		return err
+	}
+	return upsertEvent(db, newEventRecord(date, position, event))
+}
+
+// queryer is the part of *sql.DB and *sql.Tx the helpers below need
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func readVersion(q queryer) (string, error) {
+	var version string
+	err := q.QueryRow(`SELECT value FROM meta WHERE key = 'version'`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return version, err
+}
+
+// prepareWrite refuses to change a database written by a newer seyal and
+// otherwise stamps it with the current version
+func prepareWrite(q queryer, path string) error {
+	version, err := readVersion(q)
+	if err != nil {
+		return err
+	}
+	cmp, err := compareVersions(version, CurrentVersion)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if cmp > 0 {
+		return &VersionError{Path: path, Version: version}
+	}
+	_, err = q.Exec(`INSERT INTO meta (key, value) VALUES ('version', ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`, CurrentVersion)
+	return err
+}
+
+// readSettings decodes the key/value rows through the JSON field names of
+// Settings, so new settings need no schema change
+func readSettings(q queryer) (Settings, error) {
+	settings := defaultSchema().Settings
+	rows, err := q.Query(`SELECT key, value FROM settings`)
+	if err != nil {
+		return settings, err
+	}
+	defer rows.Close()
+
+	doc := make(map[string]json.RawMessage)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return settings, err
+		}
+		doc[key] = json.RawMessage(value)
+	}
+	if err := rows.Err(); err != nil {
+		return settings, err
+	}
+	if len(doc) == 0 {
+		return settings, nil
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return settings, err
+	}
+	settings = Settings{}
+	return settings, json.Unmarshal(data, &settings)
+}
+
+func writeSettings(q queryer, settings Settings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if _, err := q.Exec(`DELETE FROM settings`); err != nil {
+		return err
+	}
+	for key, value := range doc {
+		if _, err := q.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)`, key, string(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncRows upserts the rows of want that differ from have and deletes the
+// rows of have that are not in want
+func syncRows[R comparable](q queryer, table string, want, have []R, id func(R) string, upsert func(queryer, R) error) error {
+	existing := make(map[string]R, len(have))
+	for _, r := range have {
+		existing[id(r)] = r
+	}
+	for _, r := range want {
+		if old, ok := existing[id(r)]; !ok || old != r {
+			if err := upsert(q, r); err != nil {
+				return err
+			}
+		}
+		delete(existing, id(r))
+	}
+	for key := range existing {
+		if _, err := q.Exec(`DELETE FROM `+table+` WHERE id = ?`, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func upsertTask(q queryer, r taskRecord) error {
+	_, err := q.Exec(`INSERT INTO tasks (`+taskColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			parent_id = excluded.parent_id, position = excluded.position,
+			date = excluded.date, title = excluded.title, state = excluded.state,
+			priority = excluded.priority, created_at = excluded.created_at,
+			updated_at = excluded.updated_at, start_time = excluded.start_time,
+			end_time = excluded.end_time, pushed_count = excluded.pushed_count,
+			tags = excluded.tags, series_id = excluded.series_id,
+			occurrence = excluded.occurrence, due_date = excluded.due_date`,
+		r.ID, r.ParentID, r.Position, r.Date, r.Title, r.State, r.Priority,
+		r.CreatedAt, r.UpdatedAt, r.StartTime, r.EndTime, r.PushedCount, r.Tags,
+		r.SeriesID, r.Occurrence, r.DueDate)
+	return err
+}
+
+func upsertEvent(q queryer, r eventRecord) error {
+	_, err := q.Exec(`INSERT INTO timeline_events (`+eventColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			date = excluded.date, position = excluded.position,
+			task_id = excluded.task_id, task_title = excluded.task_title,
+			type = excluded.type, timestamp = excluded.timestamp,
+			previous_state = excluded.previous_state, new_state = excluded.new_state,
+			note = excluded.note`,
+		r.ID, r.Date, r.Position, r.TaskID, r.TaskTitle, r.Type,
+		r.Timestamp, r.PreviousState, r.NewState, r.Note)
+	return err
+}
+
+func upsertRecurrence(q queryer, r recurrenceRecord) error {
+	_, err := q.Exec(`INSERT INTO recurrences (id, position, rule, start, through, template) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			position = excluded.position, rule = excluded.rule, start = excluded.start,
+			through = excluded.through, template = excluded.template`,
+		r.ID, r.Position, r.Rule, r.Start, r.Through, r.Template)
+	return err
+}
+
+func queryTasks(q queryer, query string, args ...any) ([]taskRecord, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []taskRecord
+	for rows.Next() {
+		var r taskRecord
+		if err := rows.Scan(&r.ID, &r.ParentID, &r.Position, &r.Date, &r.Title, &r.State, &r.Priority,
+			&r.CreatedAt, &r.UpdatedAt, &r.StartTime, &r.EndTime, &r.PushedCount, &r.Tags,
+			&r.SeriesID, &r.Occurrence, &r.DueDate); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// querySubtree returns a task's row and the rows of all its descendants
+func querySubtree(q queryer, id string) ([]taskRecord, error) {
+	return queryTasks(q, `WITH RECURSIVE subtree(id) AS (
+			SELECT id FROM tasks WHERE id = ?
+			UNION ALL
+			SELECT tasks.id FROM tasks JOIN subtree ON tasks.parent_id = subtree.id
+		)
+		SELECT `+taskColumns+` FROM tasks WHERE id IN subtree ORDER BY position`, id)
+}
+
+func queryEvents(q queryer, query string, args ...any) ([]eventRecord, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []eventRecord
+	for rows.Next() {
+		var r eventRecord
+		if err := rows.Scan(&r.ID, &r.Date, &r.Position, &r.TaskID, &r.TaskTitle, &r.Type,
+			&r.Timestamp, &r.PreviousState, &r.NewState, &r.Note); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func queryRecurrences(q queryer) ([]recurrenceRecord, error) {
+	rows, err := q.Query(`SELECT id, position, rule, start, through, template FROM recurrences ORDER BY position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []recurrenceRecord
+	for rows.Next() {
+		var r recurrenceRecord
+		if err := rows.Scan(&r.ID, &r.Position, &r.Rule, &r.Start, &r.Through, &r.Template); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// appendTaskRecords flattens a task and its subtasks, parents first. The
+// parent column follows the tree, not the task's ParentID field.
+func appendTaskRecords(records []taskRecord, task *domain.Task, parentID string, position int) []taskRecord {
+	records = append(records, taskRecord{
+		ID:          task.ID,
+		ParentID:    sql.NullString{String: parentID, Valid: parentID != ""},
+		Position:    position,
+		Date:        task.Date,
+		Title:       task.Title,
+		State:       string(task.State),
+		Priority:    int(task.Priority),
+		CreatedAt:   formatTime(task.CreatedAt),
+		UpdatedAt:   formatTime(task.UpdatedAt),
+		StartTime:   formatTimePtr(task.StartTime),
+		EndTime:     formatTimePtr(task.EndTime),
+		PushedCount: task.PushedCount,
+		Tags:        strings.Join(task.Tags, " "),
+		SeriesID:    task.SeriesID,
+		Occurrence:  task.Occurrence,
+		DueDate:     task.DueDate,
+	})
+	for i, child := range task.Children {
+		records = appendTaskRecords(records, child, task.ID, i)
+	}
+	return records
+}
+
+// buildTree turns rows sorted by position into tasks and returns the ones
+// without a parent among them, in order
+func buildTree(records []taskRecord) []*domain.Task {
+	tasks := make(map[string]*domain.Task, len(records))
+	for _, r := range records {
+		tasks[r.ID] = r.task()
+	}
+
+	var roots []*domain.Task
+	for _, r := range records {
+		task := tasks[r.ID]
+		if parent, ok := tasks[r.ParentID.String]; ok && r.ParentID.Valid {
+			task.ParentID = parent.ID
+			parent.Children = append(parent.Children, task)
+		} else {
+			roots = append(roots, task)
+		}
+	}
+	return roots
+}
+
+func (r taskRecord) task() *domain.Task {
+	task := &domain.Task{
+		ID:          r.ID,
+		Title:       r.Title,
+		State:       domain.TaskState(r.State),
+		Priority:    domain.TaskPriority(r.Priority),
+		Date:        r.Date,
+		PushedCount: r.PushedCount,
+		Tags:        strings.Fields(r.Tags),
+		SeriesID:    r.SeriesID,
+		Occurrence:  r.Occurrence,
+		DueDate:     r.DueDate,
+	}
+	// Times were written by formatTime; a bad value leaves the zero time
+	task.CreatedAt, _ = time.Parse(time.RFC3339Nano, r.CreatedAt)
+	task.UpdatedAt, _ = time.Parse(time.RFC3339Nano, r.UpdatedAt)
+	task.StartTime = parseTimePtr(r.StartTime)
+	task.EndTime = parseTimePtr(r.EndTime)
+	return task
+}
+
+func newEventRecord(date string, position int, event *domain.TimelineEvent) eventRecord {
+	return eventRecord{
+		ID:            event.ID,
+		Date:          date,
+		Position:      position,
+		TaskID:        event.TaskID,
+		TaskTitle:     event.TaskTitle,
+		Type:          string(event.Type),
+		Timestamp:     formatTime(event.Timestamp),
+		PreviousState: string(event.PreviousState),
+		NewState:      string(event.NewState),
+		Note:          event.Note,
+	}
+}
+
+func (r eventRecord) event() (*domain.TimelineEvent, error) {
+	timestamp, err := time.Parse(time.RFC3339Nano, r.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("timeline event %s: %w", r.ID, err)
+	}
+	return &domain.TimelineEvent{
+		ID:            r.ID,
+		TaskID:        r.TaskID,
+		TaskTitle:     r.TaskTitle,
+		Type:          domain.TimelineEventType(r.Type),
+		Timestamp:     timestamp,
+		PreviousState: domain.TaskState(r.PreviousState),
+		NewState:      domain.TaskState(r.NewState),
+		Note:          r.Note,
+	}, nil
+}
+
+// The template keeps the JSON form, subtasks included, as the JSON
+// backend stores it
+func newRecurrenceRecord(position int, recurrence *domain.Recurrence) (recurrenceRecord, error) {
+	template, err := json.Marshal(recurrence.Template)
+	if err != nil {
+		return recurrenceRecord{}, err
+	}
+	return recurrenceRecord{
+		ID:       recurrence.ID,
+		Position: position,
+		Rule:     recurrence.Rule.String(),
+		Start:    recurrence.Start,
+		Through:  recurrence.Through,
+		Template: string(template),
+	}, nil
+}
+
+func (r recurrenceRecord) recurrence() (*domain.Recurrence, error) {
+	rule, err := domain.ParseRRule(r.Rule)
+	if err != nil {
+		return nil, fmt.Errorf("recurrence %s: %w", r.ID, err)
+	}
+	recurrence := &domain.Recurrence{ID: r.ID, Rule: rule, Start: r.Start, Through: r.Through}
+	if err := json.Unmarshal([]byte(r.Template), &recurrence.Template); err != nil {
+		return nil, fmt.Errorf("recurrence %s: %w", r.ID, err)
+	}
+	return recurrence, nil
+}
+
+// Times are stored in the same format encoding/json uses, so converting
+// between the backends keeps them exactly
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+func formatTimePtr(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(*t), Valid: true}
+}
+
+func parseTimePtr(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
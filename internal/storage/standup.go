@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// standupDayFormat names the days in a standup
+const standupDayFormat = "Monday, January 2"
+
+// StandupMarkdown writes a standup as Markdown, one section per heading
+func StandupMarkdown(st *domain.Standup) string {
+	var b strings.Builder
+	b.WriteString("# Standup, " + st.Date.Format(standupDayFormat) + "\n")
+	for _, section := range standupSections(st) {
+		b.WriteString("\n## " + section.title + "\n\n")
+		if len(section.items) == 0 {
+			b.WriteString("- Nothing\n")
+		}
+		for _, item := range section.items {
+			b.WriteString("- " + item + "\n")
+		}
+	}
+	return b.String()
+}
+
+// StandupText writes a standup as plain text with the timeline icons
+func StandupText(st *domain.Standup) string {
+	var b strings.Builder
+	b.WriteString("Standup, " + st.Date.Format(standupDayFormat) + "\n")
+	for _, section := range standupSections(st) {
+		b.WriteString("\n" + section.title + "\n")
+		if len(section.items) == 0 {
+			b.WriteString("  Nothing\n")
+		}
+		for i, item := range section.items {
+			b.WriteString("  " + section.icons[i] + " " + item + "\n")
+		}
+	}
+	return b.String()
+}
+
+type standupSection struct {
+	title string
+	items []string
+	icons []string
+}
+
+// standupSections lays out the standup: the previous working day with its
+// completed and pushed tasks, the day's open tasks, then what is blocked
+func standupSections(st *domain.Standup) []standupSection {
+	previous := st.Previous.Format(standupDayFormat)
+	if st.Previous == st.Date.AddDays(-1) {
+		previous = "Yesterday (" + previous + ")"
+	}
+
+	done := standupSection{title: previous}
+	for _, item := range st.Completed {
+		done.add(item.Event.GetEventIcon(), item.Title)
+	}
+	for _, item := range st.Pushed {
+		done.add(item.Event.GetEventIcon(), item.Title+" (pushed)")
+	}
+
+	today := standupSection{title: "Today"}
+	for _, item := range st.Today {
+		text := item.Title
+		if p := priorityLabel(item.Task.Priority); p != "" {
+			text += " [" + p + "]"
+		}
+		if item.Task.PushedCount > 0 {
+			text += fmt.Sprintf(" (pushed %d×)", item.Task.PushedCount)
+		}
+		today.add(stateIcon(item.Task.State), text)
+	}
+
+	blocked := standupSection{title: "Blocked"}
+	for _, item := range st.Blocked {
+		state := item.Task.State
+		blocked.add(stateIcon(state), item.Title+" ("+string(state)+")")
+	}
+	return []standupSection{done, today, blocked}
+}
+
+func (s *standupSection) add(icon, item string) {
+	s.icons = append(s.icons, icon)
+	s.items = append(s.items, item)
+}
@@ -2,20 +2,25 @@ package storage
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/krisk248/seyal/internal/domain"
+	"github.com/krisk248/seyal/internal/theme"
 )
 
 // StorageSchema represents the data structure saved to disk
 type StorageSchema struct {
-	Version  string                           `json:"version"`
-	Tasks    domain.TaskTree                  `json:"tasks"`
-	Timeline domain.Timeline                  `json:"timeline"`
-	Settings Settings                         `json:"settings"`
+	Version     string             `json:"version"`
+	Tasks       domain.TaskTree    `json:"tasks"`
+	Timeline    domain.Timeline    `json:"timeline"`
+	Recurrences domain.Recurrences `json:"recurrences,omitempty"`
+	Settings    Settings           `json:"settings"`
 }
 
 // Settings holds user preferences
@@ -24,14 +29,34 @@ type Settings struct {
 	DateFormat     string `json:"dateFormat"`
 	TimeFormat     string `json:"timeFormat"`
 	SkippedVersion string `json:"skippedVersion,omitempty"`
+
+	// Automatic backup retention, DefaultBackupRetention when unset
+	Backups *BackupRetention `json:"backups,omitempty"`
+
+	// CSV export options, the locale's defaults when unset
+	CSV *CSVOptions `json:"csv,omitempty"`
+
+	// Folder of the Markdown vault's daily notes kept in sync with the
+	// tasks, no syncing when empty
+	Vault string `json:"vault,omitempty"`
+}
+
+// CSVOptions returns the CSV export options
+func (s Settings) CSVOptions() CSVOptions {
+	if s.CSV == nil {
+		return CSVOptions{}
+	}
+	return *s.CSV
 }
 
 // Storage handles data persistence
 type Storage struct {
 	DataPath string
+	backend  Backend
 }
 
-// NewStorage creates a new storage instance
+// NewStorage creates a new storage instance. It uses the SQLite database
+// when one exists in the data directory, and data.json otherwise.
 func NewStorage() (*Storage, error) {
 	dataPath, err := getDataPath()
 	if err != nil {
@@ -44,7 +69,20 @@ func NewStorage() (*Storage, error) {
 		return nil, err
 	}
 
-	return &Storage{DataPath: dataPath}, nil
+	if _, err := os.Stat(sqlitePath(dataPath)); err == nil {
+		return OpenStorage(BackendSQLite, sqlitePath(dataPath)), nil
+	}
+	return OpenStorage(BackendJSON, dataPath), nil
+}
+
+// OpenStorage returns a storage of the given kind for the file at path
+func OpenStorage(kind BackendKind, path string) *Storage {
+	return &Storage{DataPath: path, backend: newBackend(kind, path)}
+}
+
+// Kind reports which backend the storage uses
+func (s *Storage) Kind() BackendKind {
+	return s.backend.Kind()
 }
 
 // getDataPath returns the platform-specific data file path
@@ -76,50 +114,75 @@ func getDataPath() (string, error) {
 	return filepath.Join(configDir, "data.json"), nil
 }
 
-// Load reads the data file and returns the stored data
+// Load reads all stored data
 func (s *Storage) Load() (*StorageSchema, error) {
-	data, err := os.ReadFile(s.DataPath)
+	schema, err := s.backend.Load()
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Return default schema
-			return s.defaultSchema(), nil
-		}
 		return nil, err
 	}
-
-	var schema StorageSchema
-	if err := json.Unmarshal(data, &schema); err != nil {
-		return nil, err
-	}
-
-	// Hydrate dates (JSON stores as strings, need to parse)
-	s.hydrateDates(&schema)
-
-	return &schema, nil
+	// Repeating tasks are kept as real tasks up to a few weeks ahead
+	schema.Recurrences.Expand(schema.Tasks, domain.RecurrenceHorizon())
+	return schema, nil
 }
 
-// Save writes the data to disk
+// Save replaces the stored data. The first save of each day backs up the
+// previous data first. Callers must hold the data file lock (see Lock and
+// Update).
 func (s *Storage) Save(schema *StorageSchema) error {
-	schema.Version = "1.0.0"
+	// A failed backup must not cost the user their change; it is simply
+	// tried again on the next save
+	s.dailyBackup(schema.Settings.BackupRetention())
+	return s.backend.Save(schema)
+}
 
-	data, err := json.MarshalIndent(schema, "", "  ")
+// writeFileAtomic writes data to a temp file in the same directory, syncs
+// it and renames it over path, so a crash or full disk never leaves a
+// truncated file behind
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
 	if err != nil {
 		return err
 	}
+	tmpPath := tmp.Name()
+	// Clean up the temp file on any failure before the rename
+	defer os.Remove(tmpPath)
 
-	return os.WriteFile(s.DataPath, data, 0644)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
 }
 
 // Backup creates a backup of the current data file
 func (s *Storage) Backup() error {
-	if _, err := os.Stat(s.DataPath); os.IsNotExist(err) {
+	return backupFile(s.DataPath)
+}
+
+// backupFile copies path to a timestamped backup next to it
+func backupFile(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return nil // Nothing to backup
 	}
 
-	timestamp := time.Now().Format("20060102-150405")
-	backupPath := s.DataPath + ".backup-" + timestamp
+	timestamp := time.Now().Format(backupTimeFormat)
+	backupPath := path + ".backup-" + timestamp
 
-	data, err := os.ReadFile(s.DataPath)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
@@ -128,21 +191,26 @@ func (s *Storage) Backup() error {
 }
 
 // defaultSchema returns an empty default schema
-func (s *Storage) defaultSchema() *StorageSchema {
+func defaultSchema() *StorageSchema {
 	return &StorageSchema{
-		Version:  "1.0.0",
+		Version:  CurrentVersion,
 		Tasks:    make(domain.TaskTree),
 		Timeline: make(domain.Timeline),
-		Settings: Settings{
-			Theme:      "ultraviolet",
-			DateFormat: "January 2, 2006",
-			TimeFormat: "12h",
-		},
+		Settings: DefaultSettings(),
+	}
+}
+
+// DefaultSettings returns the settings of a new data file
+func DefaultSettings() Settings {
+	return Settings{
+		Theme:      "ultraviolet",
+		DateFormat: "January 2, 2006",
+		TimeFormat: "12h",
 	}
 }
 
 // hydrateDates converts date strings back to proper Date objects if needed
-func (s *Storage) hydrateDates(schema *StorageSchema) {
+func hydrateDates(schema *StorageSchema) {
 	// Tasks and Timeline already use time.Time which JSON handles correctly
 	// with proper ISO format. This method is a placeholder for any
 	// additional date hydration needed.
@@ -174,13 +242,19 @@ func GetExportPath() (string, error) {
 	return exportDir, nil
 }
 
-// ExportToFile exports tasks to a file in the export folder
-func (s *Storage) ExportToFile(tasks domain.TaskTree, format ExportFormat, scope string, filename string) (string, error) {
-	content, err := s.Export(tasks, format, scope)
+// ExportToFile exports tasks to a file in the export folder and returns
+// the path it wrote
+func (s *Storage) ExportToFile(tasks domain.TaskTree, tl domain.Timeline, format ExportFormat, dates domain.DateRange, opts ExportOptions, filename string) (string, error) {
+	content, err := s.Export(tasks, tl, format, dates, opts)
 	if err != nil {
 		return "", err
 	}
+	return WriteExport(filename, content)
+}
 
+// WriteExport writes content to a file in the export folder and returns
+// its path
+func WriteExport(filename, content string) (string, error) {
 	exportDir, err := GetExportPath()
 	if err != nil {
 		return "", err
@@ -201,33 +275,189 @@ const (
 	FormatMarkdown ExportFormat = iota
 	FormatJSON
 	FormatPlainText
+	FormatICalendar
+	FormatTodoTxt
+	FormatTaskwarrior
+	FormatCSV
+	FormatHTML
+	FormatOrg
+	FormatTemplate
 )
 
-// Export exports tasks to the specified format (returns content as string)
-func (s *Storage) Export(tasks domain.TaskTree, format ExportFormat, scope string) (string, error) {
+// ExportOptions holds the settings of formats that have any
+type ExportOptions struct {
+	CSV CSVOptions
+	// Colors styles the HTML report; nil uses the default theme
+	Colors *theme.ColorScheme
+	// Template is the user template FormatTemplate renders
+	Template *ExportTemplate
+	// Content selects what the Markdown, JSON and plain-text exports hold
+	Content ExportContent
+}
+
+// ExportContent selects the sections of an export
+type ExportContent int
+
+const (
+	ContentAll      ExportContent = iota // Tasks, then the day's timeline
+	ContentTasks                         // Tasks only
+	ContentTimeline                      // Timeline only
+)
+
+func (c ExportContent) String() string {
+	switch c {
+	case ContentTasks:
+		return "tasks"
+	case ContentTimeline:
+		return "timeline"
+	default:
+		return "tasks and timeline"
+	}
+}
+
+// Extension returns the file extension for the format, without the dot
+func (f ExportFormat) Extension() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatPlainText:
+		return "txt"
+	case FormatICalendar:
+		return "ics"
+	case FormatTodoTxt:
+		return "todo.txt"
+	case FormatTaskwarrior:
+		return "taskwarrior.json"
+	case FormatCSV:
+		return "csv"
+	case FormatHTML:
+		return "html"
+	case FormatOrg:
+		return "org"
+	case FormatTemplate:
+		return "txt" // See ExportTemplate.Extension
+	default:
+		return "md"
+	}
+}
+
+// Export exports the tasks of the days in dates to the specified format
+// (returns content as string). Days come out in chronological order.
+// Formats that carry notes read them from tl.
+func (s *Storage) Export(tasks domain.TaskTree, tl domain.Timeline, format ExportFormat, dates domain.DateRange, opts ExportOptions) (string, error) {
 	switch format {
 	case FormatMarkdown:
-		return s.exportMarkdown(tasks, scope)
+		return s.exportMarkdown(tasks, tl, dates, opts.Content)
 	case FormatJSON:
-		return s.exportJSON(tasks, scope)
+		return s.exportJSON(tasks, tl, dates, opts.Content)
 	case FormatPlainText:
-		return s.exportPlainText(tasks, scope)
+		return s.exportPlainText(tasks, tl, dates, opts.Content)
+	case FormatICalendar:
+		return s.exportICalendar(tasks, dates)
+	case FormatTodoTxt:
+		return s.exportTodoTxt(tasks, dates)
+	case FormatTaskwarrior:
+		return s.exportTaskwarrior(tasks, tl, dates)
+	case FormatCSV:
+		return s.exportCSV(tasks, dates, opts.CSV)
+	case FormatHTML:
+		return s.exportHTML(tasks, tl, dates, opts.Colors)
+	case FormatOrg:
+		return s.exportOrg(tasks, dates)
+	case FormatTemplate:
+		return s.exportTemplate(tasks, tl, dates, opts.Template)
 	default:
-		return s.exportMarkdown(tasks, scope)
+		return s.exportMarkdown(tasks, tl, dates, opts.Content)
 	}
 }
 
-func (s *Storage) exportMarkdown(tasks domain.TaskTree, scope string) (string, error) {
-	var result string
+// exportDates returns the days in range that have tasks, in order
+func exportDates(tasks domain.TaskTree, dates domain.DateRange) []string {
+	var result []string
+	for _, date := range tasks.Dates() {
+		if dates.Contains(date) {
+			result = append(result, date)
+		}
+	}
+	return result
+}
 
-	for date, taskList := range tasks {
-		if scope != "all" && scope != date {
-			continue
+// reportDates returns the days in range with tasks or timeline events, in
+// order. Either map may be nil.
+func reportDates(tasks domain.TaskTree, tl domain.Timeline, dates domain.DateRange) []string {
+	seen := make(map[string]bool)
+	for date, list := range tasks {
+		if len(list) > 0 && dates.Contains(date) {
+			seen[date] = true
+		}
+	}
+	for date, events := range tl {
+		if len(events) > 0 && dates.Contains(date) {
+			seen[date] = true
 		}
+	}
+	result := make([]string, 0, len(seen))
+	for date := range seen {
+		result = append(result, date)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// contentDates returns the days in range with something to export
+func contentDates(tasks domain.TaskTree, tl domain.Timeline, dates domain.DateRange, content ExportContent) []string {
+	switch content {
+	case ContentTasks:
+		return exportDates(tasks, dates)
+	case ContentTimeline:
+		return reportDates(nil, tl, dates)
+	default:
+		return reportDates(tasks, tl, dates)
+	}
+}
+
+// sortedEvents returns a day's events oldest first
+func sortedEvents(events []*domain.TimelineEvent) []*domain.TimelineEvent {
+	sorted := append([]*domain.TimelineEvent(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+	return sorted
+}
 
+// eventText describes an event the way the timeline pane does, as in
+// "● completed Draft" or "✎ note on Draft: sent to Ana"
+func eventText(event *domain.TimelineEvent) string {
+	text := event.GetEventIcon() + " " + event.GetEventDescription() + " " + event.TaskTitle
+	if event.Note != "" {
+		text += ": " + strings.Join(strings.Fields(event.Note), " ")
+	}
+	return text
+}
+
+// eventTimeFormat is the time of day shown for timeline events
+const eventTimeFormat = "3:04 PM"
+
+func (s *Storage) exportMarkdown(tasks domain.TaskTree, tl domain.Timeline, dates domain.DateRange, content ExportContent) (string, error) {
+	var result string
+
+	for _, date := range contentDates(tasks, tl, dates, content) {
 		result += "## " + date + "\n\n"
-		for _, task := range taskList {
-			result += s.taskToMarkdown(task, 0)
+		taskList := tasks[date]
+		if content != ContentTimeline && len(taskList) > 0 {
+			for _, task := range taskList {
+				result += s.taskToMarkdown(task, 0)
+			}
+			result += "\n"
+		}
+
+		events := tl[date]
+		if content == ContentTasks || len(events) == 0 {
+			continue
+		}
+		if content == ContentAll {
+			result += "### Timeline\n\n"
+		}
+		for _, event := range sortedEvents(events) {
+			result += "- " + event.Timestamp.Local().Format(eventTimeFormat) + " " + eventText(event) + "\n"
 		}
 		result += "\n"
 	}
@@ -247,16 +477,16 @@ func (s *Storage) taskToMarkdown(task *domain.Task, depth int) string {
 	}
 
 	priority := ""
-	switch task.Priority {
-	case domain.PriorityHigh:
-		priority = " **P1**"
-	case domain.PriorityMed:
-		priority = " *P2*"
-	case domain.PriorityLow:
-		priority = " P3"
+	if p := markdownPriorityLabel(task.Priority); p != "" {
+		priority = " " + p
+	}
+
+	tags := ""
+	if len(task.Tags) > 0 {
+		tags = " " + task.TagText()
 	}
 
-	result := indent + "- " + checkbox + " " + task.Title + priority + "\n"
+	result := indent + "- " + checkbox + " " + task.Title + tags + priority + "\n"
 
 	for _, child := range task.Children {
 		result += s.taskToMarkdown(child, depth+1)
@@ -265,18 +495,62 @@ func (s *Storage) taskToMarkdown(task *domain.Task, depth int) string {
 	return result
 }
 
-func (s *Storage) exportJSON(tasks domain.TaskTree, scope string) (string, error) {
-	var filtered domain.TaskTree
-	if scope == "all" {
-		filtered = tasks
-	} else {
-		filtered = make(domain.TaskTree)
-		if taskList, ok := tasks[scope]; ok {
-			filtered[scope] = taskList
+// markdownPriorityLabel writes a priority the way parseMarkdown reads it
+func markdownPriorityLabel(priority domain.TaskPriority) string {
+	switch priority {
+	case domain.PriorityHigh:
+		return "**P1**"
+	case domain.PriorityMed:
+		return "*P2*"
+	case domain.PriorityLow:
+		return "P3"
+	}
+	return ""
+}
+
+// jsonExport is the document the JSON export writes when it includes the
+// timeline. Its tasks and timeline are keyed by day like the data file's.
+type jsonExport struct {
+	Tasks    domain.TaskTree        `json:"tasks,omitempty"`
+	Timeline map[string][]jsonEvent `json:"timeline,omitempty"`
+}
+
+// jsonEvent is a timeline event with the icon and description the
+// timeline pane shows for it
+type jsonEvent struct {
+	*domain.TimelineEvent
+	Icon        string `json:"icon"`
+	Description string `json:"description"`
+}
+
+func (s *Storage) exportJSON(tasks domain.TaskTree, tl domain.Timeline, dates domain.DateRange, content ExportContent) (string, error) {
+	// Map keys are marshalled in sorted, and so chronological, order
+	var export jsonExport
+	if content != ContentTimeline {
+		export.Tasks = make(domain.TaskTree)
+		for _, date := range exportDates(tasks, dates) {
+			export.Tasks[date] = tasks[date]
+		}
+	}
+	if content != ContentTasks {
+		export.Timeline = make(map[string][]jsonEvent)
+		for _, date := range reportDates(nil, tl, dates) {
+			for _, event := range sortedEvents(tl[date]) {
+				export.Timeline[date] = append(export.Timeline[date], jsonEvent{
+					TimelineEvent: event,
+					Icon:          event.GetEventIcon(),
+					Description:   event.GetEventDescription(),
+				})
+			}
 		}
 	}
 
-	data, err := json.MarshalIndent(filtered, "", "  ")
+	// Without the timeline, the tasks are written on their own as before
+	var doc any = export
+	if content == ContentTasks {
+		doc = export.Tasks
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
 	if err != nil {
 		return "", err
 	}
@@ -284,18 +558,30 @@ func (s *Storage) exportJSON(tasks domain.TaskTree, scope string) (string, error
 	return string(data), nil
 }
 
-func (s *Storage) exportPlainText(tasks domain.TaskTree, scope string) (string, error) {
+func (s *Storage) exportPlainText(tasks domain.TaskTree, tl domain.Timeline, dates domain.DateRange, content ExportContent) (string, error) {
 	var result string
 
-	for date, taskList := range tasks {
-		if scope != "all" && scope != date {
-			continue
-		}
-
+	for _, date := range contentDates(tasks, tl, dates, content) {
+		taskList := tasks[date]
 		result += date + "\n"
 		result += "─────────────────────\n"
-		for _, task := range taskList {
-			result += s.taskToPlainText(task, 0)
+		if content != ContentTimeline {
+			for _, task := range taskList {
+				result += s.taskToPlainText(task, 0)
+			}
+		}
+
+		events := tl[date]
+		if content != ContentTasks && len(events) > 0 {
+			if content == ContentAll {
+				if len(taskList) > 0 {
+					result += "\n"
+				}
+				result += "Timeline\n"
+			}
+			for _, event := range sortedEvents(events) {
+				result += fmt.Sprintf("  %8s  %s\n", event.Timestamp.Local().Format(eventTimeFormat), eventText(event))
+			}
 		}
 		result += "\n"
 	}
@@ -318,7 +604,11 @@ func (s *Storage) taskToPlainText(task *domain.Task, depth int) string {
 		status = "‖"
 	}
 
-	result := indent + status + " " + task.Title + "\n"
+	result := indent + status + " " + task.Title
+	if len(task.Tags) > 0 {
+		result += " " + task.TagText()
+	}
+	result += "\n"
 
 	for _, child := range task.Children {
 		result += s.taskToPlainText(child, depth+1)
@@ -0,0 +1,326 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// Taskwarrior support, in the JSON array format of `task export` and
+// `task import`. Subtasks are expressed the Taskwarrior way: a parent
+// depends on its subtasks. Delegated and delayed tasks are pending tasks
+// tagged "delegated" or "delayed", next to the task's own tags. Note events
+// become annotations.
+
+const taskwarriorTimeFormat = "20060102T150405Z"
+
+// twAnnotation is a Taskwarrior annotation
+type twAnnotation struct {
+	Entry       string `json:"entry"`
+	Description string `json:"description"`
+}
+
+// twTask holds the Taskwarrior attributes seyal writes
+type twTask struct {
+	UUID        string         `json:"uuid"`
+	Description string         `json:"description"`
+	Status      string         `json:"status"`
+	Entry       string         `json:"entry"`
+	Modified    string         `json:"modified,omitempty"`
+	Start       string         `json:"start,omitempty"`
+	End         string         `json:"end,omitempty"`
+	Due         string         `json:"due,omitempty"`
+	Scheduled   string         `json:"scheduled,omitempty"`
+	Priority    string         `json:"priority,omitempty"`
+	Depends     []string       `json:"depends,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`
+	Annotations []twAnnotation `json:"annotations,omitempty"`
+}
+
+// exportTaskwarrior writes the tasks of the days in dates, subtasks
+// included, with their notes from tl as annotations
+func (s *Storage) exportTaskwarrior(tasks domain.TaskTree, tl domain.Timeline, dates domain.DateRange) (string, error) {
+	notes := make(map[string][]*domain.TimelineEvent)
+	for _, events := range tl {
+		for _, event := range events {
+			if event.Type == domain.EventNote {
+				notes[event.TaskID] = append(notes[event.TaskID], event)
+			}
+		}
+	}
+
+	result := make([]twTask, 0)
+	for _, date := range exportDates(tasks, dates) {
+		for _, ft := range domain.FlattenTasks(tasks[date], 0, false) {
+			result = append(result, taskToTaskwarrior(ft.Task, notes[ft.Task.ID]))
+		}
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func taskToTaskwarrior(task *domain.Task, notes []*domain.TimelineEvent) twTask {
+	tw := twTask{
+		UUID:        task.ID,
+		Description: task.Title,
+		Status:      "pending",
+		Entry:       twTime(task.CreatedAt),
+		Modified:    twTime(task.UpdatedAt),
+		Priority:    twPriority(task.Priority),
+	}
+	if day, err := domain.ParseCalendarDate(task.Date); err == nil {
+		tw.Scheduled = twTime(day.Time())
+	}
+	if due, err := domain.ParseCalendarDate(task.DueDate); err == nil {
+		tw.Due = twTime(due.Time())
+	}
+
+	switch task.State {
+	case domain.TaskStateCompleted:
+		tw.Status = "completed"
+		end := task.UpdatedAt
+		if task.EndTime != nil {
+			end = *task.EndTime
+		}
+		tw.End = twTime(end)
+	case domain.TaskStateDelegated, domain.TaskStateDelayed:
+		tw.Tags = []string{string(task.State)}
+	}
+	tw.Tags = append(tw.Tags, task.Tags...)
+	if task.IsRunning() {
+		tw.Start = twTime(*task.StartTime)
+	}
+
+	for _, child := range task.Children {
+		tw.Depends = append(tw.Depends, child.ID)
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Timestamp.Before(notes[j].Timestamp) })
+	for _, note := range notes {
+		tw.Annotations = append(tw.Annotations, twAnnotation{Entry: twTime(note.Timestamp), Description: note.Note})
+	}
+	return tw
+}
+
+func twTime(t time.Time) string {
+	return t.UTC().Format(taskwarriorTimeFormat)
+}
+
+func twPriority(p domain.TaskPriority) string {
+	switch p {
+	case domain.PriorityHigh:
+		return "H"
+	case domain.PriorityMed:
+		return "M"
+	case domain.PriorityLow:
+		return "L"
+	default:
+		return ""
+	}
+}
+
+// twIgnored are attributes Taskwarrior computes on export; dropping them
+// loses nothing
+var twIgnored = map[string]bool{"id": true, "urgency": true}
+
+// parseTaskwarrior reads `task export` output. Deleted tasks and
+// recurrence templates are skipped. Attributes seyal has no place for are
+// listed in the returned warnings rather than dropped silently.
+func parseTaskwarrior(r io.Reader, date string) (*ImportData, error) {
+	var raw []map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("not a Taskwarrior export: %w", err)
+	}
+
+	data := &ImportData{}
+	var tasks []*domain.Task
+	seen := make(map[string]bool)
+	parents := make(map[string]string) // Child UUID to parent UUID
+	unmapped := make(map[string]int)   // Attribute name to task count
+	skipped := make(map[string]int)    // Status to task count
+
+	for i, attrs := range raw {
+		var tw struct {
+			twTask
+			Wait    string          `json:"wait"`
+			Parent  string          `json:"parent"`
+			Depends json.RawMessage `json:"depends"`
+		}
+		obj, _ := json.Marshal(attrs)
+		if err := json.Unmarshal(obj, &tw); err != nil {
+			return nil, fmt.Errorf("task %d: %w", i+1, err)
+		}
+		if tw.Status == "deleted" || tw.Status == "recurring" {
+			skipped[tw.Status]++
+			continue
+		}
+
+		task, err := taskwarriorTask(tw.twTask, date)
+		if err != nil {
+			return nil, fmt.Errorf("task %d: %w", i+1, err)
+		}
+		if tw.Status == "waiting" && task.State == domain.TaskStateTodo {
+			task.State = domain.TaskStateDelayed
+		}
+		if seen[task.ID] {
+			continue
+		}
+		seen[task.ID] = true
+		tasks = append(tasks, task)
+
+		depends, err := twDepends(tw.Depends)
+		if err != nil {
+			return nil, fmt.Errorf("task %d: %w", i+1, err)
+		}
+		for _, child := range depends {
+			if _, claimed := parents[child]; !claimed {
+				parents[child] = task.ID
+			}
+		}
+		if tw.Parent != "" {
+			task.ParentID = tw.Parent
+		}
+
+		for _, a := range tw.Annotations {
+			at, err := time.Parse(taskwarriorTimeFormat, a.Entry)
+			if err != nil {
+				at = task.CreatedAt
+			}
+			data.Events = append(data.Events, domain.NewNoteEvent(task.ID, task.Title, a.Description, at))
+		}
+
+		for key := range attrs {
+			if !twMapped(key) {
+				unmapped[key]++
+			}
+		}
+	}
+
+	// depends takes precedence over parent, which Taskwarrior itself uses
+	// to link recurring tasks to their template
+	for _, task := range tasks {
+		if parent, ok := parents[task.ID]; ok {
+			task.ParentID = parent
+		}
+	}
+	data.Tasks = nestTasks(tasks)
+
+	for _, status := range []string{"deleted", "recurring"} {
+		if n := skipped[status]; n > 0 {
+			data.Warnings = append(data.Warnings, fmt.Sprintf("skipped %d %s tasks", n, status))
+		}
+	}
+	for _, key := range sortedKeys(unmapped) {
+		data.Warnings = append(data.Warnings, fmt.Sprintf("ignored attribute %q on %d tasks", key, unmapped[key]))
+	}
+	return data, nil
+}
+
+// twMapped reports whether an attribute is carried over
+func twMapped(key string) bool {
+	switch key {
+	case "uuid", "description", "status", "entry", "modified", "start", "end",
+		"due", "scheduled", "wait", "priority", "depends", "parent", "tags", "annotations":
+		return true
+	}
+	return twIgnored[key]
+}
+
+// taskwarriorTask maps the attributes of one task. due is the due date.
+// The day is the scheduled date, else the due date, else the completion
+// date; tasks with none of those go on date.
+func taskwarriorTask(tw twTask, date string) (*domain.Task, error) {
+	task := domain.NewTask(strings.TrimSpace(tw.Description), date)
+	if task.Title == "" {
+		task.Title = "Untitled task"
+	}
+	if tw.UUID != "" {
+		task.ID = tw.UUID
+	}
+
+	var err error
+	parse := func(s string) *time.Time {
+		if s == "" || err != nil {
+			return nil
+		}
+		t, perr := time.Parse(taskwarriorTimeFormat, s)
+		if perr != nil {
+			err = fmt.Errorf("invalid date %q", s)
+			return nil
+		}
+		return &t
+	}
+	entry, modified, start, end := parse(tw.Entry), parse(tw.Modified), parse(tw.Start), parse(tw.End)
+	due, sched := parse(tw.Due), parse(tw.Scheduled)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil {
+		task.CreatedAt, task.UpdatedAt = *entry, *entry
+	}
+	if modified != nil {
+		task.UpdatedAt = *modified
+	}
+	switch tw.Status {
+	case "completed":
+		task.State = domain.TaskStateCompleted
+		task.EndTime = end
+	default:
+		for _, tag := range tw.Tags {
+			if state, err := domain.ParseTaskState(tag); err == nil && state != domain.TaskStateCompleted {
+				task.State = state
+			}
+		}
+		task.StartTime = start
+	}
+	for _, tag := range tw.Tags {
+		if tag != string(domain.TaskStateDelegated) && tag != string(domain.TaskStateDelayed) {
+			task.Tags = domain.AddTag(task.Tags, tag)
+		}
+	}
+	switch strings.ToUpper(tw.Priority) {
+	case "H":
+		task.Priority = domain.PriorityHigh
+	case "M":
+		task.Priority = domain.PriorityMed
+	case "L":
+		task.Priority = domain.PriorityLow
+	}
+
+	if due != nil {
+		task.DueDate = domain.NewCalendarDate(due.Local()).String()
+	}
+	for _, t := range []*time.Time{sched, due, end} {
+		if t != nil {
+			task.Date = domain.NewCalendarDate(t.Local()).String()
+			break
+		}
+	}
+	return task, nil
+}
+
+// twDepends reads depends, which Taskwarrior writes as an array or, before
+// version 2.6, as a comma-separated string
+func twDepends(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("invalid depends %s", raw)
+	}
+	return strings.Split(s, ","), nil
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// templateExt marks files in the template folder as export templates
+const templateExt = ".tmpl"
+
+// ExportTemplate is a user export template: a text/template file in the
+// template folder. The name is the file name without ".tmpl", and what is
+// left of its extension names the output, so "standup.md.tmpl" writes
+// Markdown.
+type ExportTemplate struct {
+	Name string
+	Path string
+}
+
+// Extension returns the file extension for the template's output, without
+// the dot. Templates without one write .txt files.
+func (t ExportTemplate) Extension() string {
+	if ext := filepath.Ext(t.Name); ext != "" {
+		return ext[1:]
+	}
+	return "txt"
+}
+
+// GetTemplatePath returns the folder export templates are read from, the
+// seyal folder in the platform's config directory
+func GetTemplatePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "seyal", "templates"), nil
+}
+
+// ExportTemplates returns the templates in the template folder, by name.
+// A missing folder has none.
+func ExportTemplates() ([]ExportTemplate, error) {
+	dir, err := GetTemplatePath()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var templates []ExportTemplate
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), templateExt)
+		if !ok || name == "" || entry.IsDir() {
+			continue
+		}
+		templates = append(templates, ExportTemplate{Name: name, Path: filepath.Join(dir, entry.Name())})
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// TemplateData is what an export template renders. It is documented in the
+// README, so fields are only ever added.
+type TemplateData struct {
+	// Range is the exported range as "2026-01-05", "2026-01-05..2026-01-11"
+	// or "all"; From and To are its ends, empty when open
+	Range    string
+	From, To string
+	// Generated is when the export was written
+	Generated time.Time
+	// Days are the days in range with tasks or timeline events, in order
+	Days []TemplateDay
+	// Stats covers every task in Days
+	Stats TemplateStats
+}
+
+// TemplateDay is one day of an export
+type TemplateDay struct {
+	// Date is the day as YYYY-MM-DD; Time is its local midnight, for
+	// {{.Time.Format "Monday, January 2"}}
+	Date string
+	Time time.Time
+	// Tasks lists the day's tasks in the order the task pane shows them,
+	// each followed by its subtasks
+	Tasks []TemplateTask
+	// Events is the day's timeline, oldest first
+	Events []*domain.TimelineEvent
+	Stats  TemplateStats
+}
+
+// TemplateTask is a task with its place in the tree. The task's own fields,
+// such as Title, State, Priority and PushedCount, are available directly.
+type TemplateTask struct {
+	*domain.Task
+	// Depth is 0 for top-level tasks, 1 for their subtasks and so on
+	Depth int
+	// Tracked is the time between starting and stopping the task, zero if
+	// it was never stopped
+	Tracked time.Duration
+}
+
+// TemplateStats counts tasks, subtasks included
+type TemplateStats struct {
+	Total, Todo, Completed, Delegated, Delayed int
+	// Percent is the share of completed tasks, 0 to 100
+	Percent int
+	// Tracked is the tracked time of all tasks
+	Tracked time.Duration
+}
+
+func (st *TemplateStats) add(task TemplateTask) {
+	st.Total++
+	switch task.State {
+	case domain.TaskStateCompleted:
+		st.Completed++
+	case domain.TaskStateDelegated:
+		st.Delegated++
+	case domain.TaskStateDelayed:
+		st.Delayed++
+	default:
+		st.Todo++
+	}
+	st.Tracked += task.Tracked
+	st.Percent = st.Completed * 100 / st.Total
+}
+
+// templateFuncs are the helpers export templates can call
+var templateFuncs = template.FuncMap{
+	"duration":  formatDuration,
+	"priority":  priorityLabel,
+	"stateIcon": stateIcon,
+	"indent": func(depth int) string {
+		return strings.Repeat("  ", depth)
+	},
+}
+
+// exportTemplate renders the user template t over the days in dates
+func (s *Storage) exportTemplate(tasks domain.TaskTree, tl domain.Timeline, dates domain.DateRange, t *ExportTemplate) (string, error) {
+	if t == nil {
+		return "", fmt.Errorf("no export template selected")
+	}
+	text, err := os.ReadFile(t.Path)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(t.Name).Funcs(templateFuncs).Parse(string(text))
+	if err != nil {
+		return "", err
+	}
+
+	data := TemplateData{Range: dates.String(), From: dates.From, To: dates.To, Generated: time.Now()}
+	for _, date := range reportDates(tasks, tl, dates) {
+		day := TemplateDay{Date: date, Events: sortedEvents(tl[date])}
+		if d, err := domain.ParseCalendarDate(date); err == nil {
+			day.Time = d.Time()
+		}
+		for _, ft := range domain.FlattenTasks(tasks[date], 0, false) {
+			task := TemplateTask{Task: ft.Task, Depth: ft.Depth}
+			task.Tracked, _ = trackedTime(ft.Task)
+			day.Tasks = append(day.Tasks, task)
+			day.Stats.add(task)
+			data.Stats.add(task)
+		}
+		data.Days = append(data.Days, day)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// formatDuration writes a duration as "1h 05m" or "45m"
+func formatDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute).Minutes())
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
+
+// stateIcon returns the timeline icon of the event that puts a task in
+// state. Open tasks get the "started" circle.
+func stateIcon(state domain.TaskState) string {
+	event := domain.TimelineEvent{Type: domain.EventStarted}
+	switch state {
+	case domain.TaskStateCompleted:
+		event.Type = domain.EventCompleted
+	case domain.TaskStateDelegated:
+		event.Type = domain.EventDelegated
+	case domain.TaskStateDelayed:
+		event.Type = domain.EventDelayed
+	}
+	return event.GetEventIcon()
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// todo.txt support (https://github.com/todotxt/todo.txt). Each task,
+// subtask included, is one line:
+//
+//	x 2026-01-03 2026-01-02 Title t:2026-01-02 due:2026-01-05 id:<uuid> pri:A
+//
+// t: (threshold) is the planned day and due: the due date, if any. id: and
+// parent: keep the tree, and state: keeps the delegated and delayed
+// states. Tags are written as "#tag" words after the title. Completed lines
+// carry their priority as pri: since the format drops the (A) prefix on
+// completion.
+
+const todoTxtDateFormat = "2006-01-02"
+
+// exportTodoTxt writes the tasks of the days in dates as todo.txt lines
+func (s *Storage) exportTodoTxt(tasks domain.TaskTree, dates domain.DateRange) (string, error) {
+	var b strings.Builder
+	for _, date := range exportDates(tasks, dates) {
+		for _, ft := range domain.FlattenTasks(tasks[date], 0, false) {
+			b.WriteString(taskToTodoTxt(ft.Task) + "\n")
+		}
+	}
+	return b.String(), nil
+}
+
+func taskToTodoTxt(task *domain.Task) string {
+	var parts []string
+	priority := todoTxtPriority(task.Priority)
+	if task.State == domain.TaskStateCompleted {
+		parts = append(parts, "x")
+		if task.EndTime != nil {
+			parts = append(parts, task.EndTime.Local().Format(todoTxtDateFormat))
+		} else {
+			parts = append(parts, task.UpdatedAt.Local().Format(todoTxtDateFormat))
+		}
+	} else if priority != "" {
+		parts = append(parts, "("+priority+")")
+	}
+	parts = append(parts, task.CreatedAt.Local().Format(todoTxtDateFormat))
+
+	// A line break would start a new task
+	parts = append(parts, strings.Join(strings.Fields(task.Title), " "))
+	if len(task.Tags) > 0 {
+		parts = append(parts, task.TagText())
+	}
+	parts = append(parts, "t:"+task.Date)
+	if task.DueDate != "" {
+		parts = append(parts, "due:"+task.DueDate)
+	}
+	parts = append(parts, "id:"+task.ID)
+	if task.ParentID != "" {
+		parts = append(parts, "parent:"+task.ParentID)
+	}
+	if task.State == domain.TaskStateDelegated || task.State == domain.TaskStateDelayed {
+		parts = append(parts, "state:"+string(task.State))
+	}
+	if task.State == domain.TaskStateCompleted && priority != "" {
+		parts = append(parts, "pri:"+priority)
+	}
+	return strings.Join(parts, " ")
+}
+
+// todoTxtPriority maps P1-P3 to the letters A-C
+func todoTxtPriority(p domain.TaskPriority) string {
+	switch p {
+	case domain.PriorityHigh:
+		return "A"
+	case domain.PriorityMed:
+		return "B"
+	case domain.PriorityLow:
+		return "C"
+	default:
+		return ""
+	}
+}
+
+// taskPriorityFromLetter maps a todo.txt priority back. Letters past C are
+// all low priority.
+func taskPriorityFromLetter(letter string) domain.TaskPriority {
+	if len(letter) != 1 || letter[0] < 'A' || letter[0] > 'Z' {
+		return domain.PriorityNone
+	}
+	switch letter[0] {
+	case 'A':
+		return domain.PriorityHigh
+	case 'B':
+		return domain.PriorityMed
+	default:
+		return domain.PriorityLow
+	}
+}
+
+// parseTodoTxt reads a todo.txt file. Lines go on their t: day, else on
+// their due: day, else on date, and
+// parent: nests a task under the line with that id: (see nestTasks).
+// "#tag" words become tags; projects, contexts and unknown key:value pairs
+// stay in the title.
+func parseTodoTxt(r io.Reader, date string) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		task := todoTxtTask(scanner.Text(), date)
+		if task == nil || seen[task.ID] {
+			continue
+		}
+		seen[task.ID] = true
+		tasks = append(tasks, task)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nestTasks(tasks), nil
+}
+
+// todoTxtTask parses one line, returning nil for blank lines
+func todoTxtTask(line string, date string) *domain.Task {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	task := domain.NewTask("", date)
+
+	// Leading "x [completion date]" or "(A)", then an optional creation date
+	if fields[0] == "x" {
+		task.State = domain.TaskStateCompleted
+		fields = fields[1:]
+		if len(fields) > 0 {
+			if t, ok := todoTxtDate(fields[0]); ok {
+				task.EndTime = &t
+				fields = fields[1:]
+			}
+		}
+	} else if f := fields[0]; len(f) == 3 && f[0] == '(' && f[2] == ')' {
+		task.Priority = taskPriorityFromLetter(f[1:2])
+		fields = fields[1:]
+	}
+	if len(fields) > 0 {
+		if t, ok := todoTxtDate(fields[0]); ok {
+			task.CreatedAt = t
+			task.UpdatedAt = t
+			fields = fields[1:]
+		}
+	}
+	if task.EndTime != nil && task.UpdatedAt.Before(*task.EndTime) {
+		task.UpdatedAt = *task.EndTime
+	}
+
+	var planned string
+	var words []string
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok || value == "" {
+			words = append(words, field)
+			continue
+		}
+		switch key {
+		case "t":
+			if _, err := domain.ParseCalendarDate(value); err == nil {
+				planned = value
+				continue
+			}
+		case "due":
+			if _, err := domain.ParseCalendarDate(value); err == nil {
+				task.DueDate = value
+				continue
+			}
+		case "id":
+			task.ID = value
+			continue
+		case "parent":
+			task.ParentID = value
+			continue
+		case "state":
+			if state, err := domain.ParseTaskState(value); err == nil && task.State != domain.TaskStateCompleted {
+				task.State = state
+				continue
+			}
+		case "pri":
+			if p := taskPriorityFromLetter(value); p != domain.PriorityNone {
+				task.Priority = p
+				continue
+			}
+		}
+		words = append(words, field)
+	}
+	switch {
+	case planned != "":
+		task.Date = planned
+	case task.DueDate != "":
+		task.Date = task.DueDate
+	}
+
+	task.Title, task.Tags = domain.ParseTags(strings.Join(words, " "))
+	if task.Title == "" {
+		task.Title = "Untitled task"
+	}
+	return task
+}
+
+// todoTxtDate parses a YYYY-MM-DD date as local midnight
+func todoTxtDate(s string) (time.Time, bool) {
+	t, err := time.ParseInLocation(todoTxtDateFormat, s, time.Local)
+	return t, err == nil
+}
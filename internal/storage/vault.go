@@ -0,0 +1,399 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/krisk248/seyal/internal/domain"
+)
+
+// The marker comments around the synced section of a daily note. Markdown
+// renderers hide them, so the note shows a plain checklist.
+const (
+	vaultBegin = "<!-- seyal:begin -->"
+	vaultEnd   = "<!-- seyal:end -->"
+)
+
+// vaultStateFile sits next to the data file and holds each section as last
+// written, the base that tells edits in the note from edits in seyal
+const vaultStateFile = "vault-sync.json"
+
+// vaultItem is a synced task line, "- [x] Title **P1** <!-- seyal:ID -->".
+// The ID comment ties the line to its task.
+var vaultItem = regexp.MustCompile(`^([ \t]*)- \[(.)\] (.*) <!-- seyal:([0-9A-Za-z-]+) -->$`)
+
+// vaultBoxes are the checkbox marks of each state. Delegated and delayed
+// use the forwarded and scheduled marks common in Markdown vaults.
+var vaultBoxes = map[domain.TaskState]string{
+	domain.TaskStateTodo:      " ",
+	domain.TaskStateCompleted: "x",
+	domain.TaskStateDelegated: ">",
+	domain.TaskStateDelayed:   "<",
+}
+
+// vaultBoxState returns the state a checkbox mark stands for
+func vaultBoxState(box string) (domain.TaskState, bool) {
+	if box == "X" {
+		return domain.TaskStateCompleted, true
+	}
+	for state, b := range vaultBoxes {
+		if b == box {
+			return state, true
+		}
+	}
+	return "", false
+}
+
+// Vault keeps a section of each YYYY-MM-DD.md daily note in a Markdown
+// vault in sync with the day's tasks. Checkboxes ticked or changed in a
+// note are read back as state changes; a note edited where seyal changed
+// the same task, or edited beyond its checkboxes, is a conflict and is left
+// alone until the user resolves it. Notes are never created, so a vault's
+// own daily-note template still applies.
+type Vault struct {
+	Dir       string // The folder holding the daily notes
+	statePath string
+}
+
+// Vault returns the vault whose daily notes are in dir
+func (s *Storage) Vault(dir string) *Vault {
+	return &Vault{Dir: dir, statePath: filepath.Join(filepath.Dir(s.DataPath), vaultStateFile)}
+}
+
+// VaultChange is a checkbox changed in a note since seyal last wrote it
+type VaultChange struct {
+	Date   string
+	TaskID string
+	Title  string
+	State  domain.TaskState // The state the checkbox now stands for
+}
+
+// VaultConflict is a note seyal will not rewrite, and why
+type VaultConflict struct {
+	Date   string
+	Title  string // The task concerned, empty when it is the whole section
+	Reason string
+}
+
+func (c VaultConflict) String() string {
+	if c.Title == "" {
+		return c.Date + ".md: " + c.Reason
+	}
+	return c.Date + ".md: " + c.Title + ": " + c.Reason
+}
+
+// VaultReport is what reading the vault found
+type VaultReport struct {
+	Changes   []VaultChange
+	Conflicts []VaultConflict
+}
+
+// Apply makes the report's changes to tasks, logging them on the timeline,
+// and returns how many tasks changed
+func (r *VaultReport) Apply(tasks domain.TaskTree, tl domain.Timeline) int {
+	changed := 0
+	for _, change := range r.Changes {
+		task := tasks.FindTask(change.TaskID)
+		if task == nil || task.State == change.State {
+			continue
+		}
+		domain.ChangeTaskState(tl, task, change.State)
+		changed++
+	}
+	return changed
+}
+
+// vaultSyncState is the vault state file
+type vaultSyncState struct {
+	Dir   string            `json:"dir"`
+	Notes map[string]string `json:"notes"` // Section body as last written, by day
+}
+
+// vaultNote is one daily note as found on disk
+type vaultNote struct {
+	date      string
+	path      string
+	content   string // The whole note, "" when it does not exist
+	exists    bool
+	begin     int // Offsets of the section body in content, -1 without markers
+	end       int
+	body      string
+	want      string // The section body for the day's tasks
+	changes   []VaultChange
+	conflicts []VaultConflict
+}
+
+// Read returns the checkbox changes made in the notes since the last Write,
+// and the conflicts that stop notes from being rewritten
+func (v *Vault) Read(tasks domain.TaskTree) (*VaultReport, error) {
+	notes, _, err := v.scan(tasks)
+	if err != nil {
+		return nil, err
+	}
+	report := &VaultReport{}
+	for _, note := range notes {
+		report.Changes = append(report.Changes, note.changes...)
+		report.Conflicts = append(report.Conflicts, note.conflicts...)
+	}
+	return report, nil
+}
+
+// Write brings each note's section up to date with tasks, adding it at the
+// end of notes without one. Notes with changes not yet applied, or with
+// conflicts, are left as they are; their conflicts are returned.
+func (v *Vault) Write(tasks domain.TaskTree) ([]VaultConflict, error) {
+	notes, state, err := v.scan(tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []VaultConflict
+	written := false
+	for _, note := range notes {
+		conflicts = append(conflicts, note.conflicts...)
+		if len(note.conflicts) > 0 || note.pending(tasks) {
+			continue
+		}
+		if !note.exists || (note.begin < 0 && note.want == "") {
+			continue
+		}
+
+		content := note.content
+		switch {
+		case note.begin < 0:
+			if content != "" && !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+			if content != "" {
+				content += "\n"
+			}
+			content += vaultBegin + "\n" + note.want + vaultEnd + "\n"
+		case note.body != note.want:
+			content = content[:note.begin] + note.want + content[note.end:]
+		}
+		if content != note.content {
+			if err := writeFileAtomic(note.path, []byte(content), 0644); err != nil {
+				return conflicts, err
+			}
+		}
+		if base, ok := state.Notes[note.date]; !ok || base != note.want {
+			state.Notes[note.date] = note.want
+			written = true
+		}
+	}
+
+	if written {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false) // Keep the marker comments readable
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(state); err != nil {
+			return conflicts, err
+		}
+		if err := writeFileAtomic(v.statePath, buf.Bytes(), 0600); err != nil {
+			return conflicts, err
+		}
+	}
+	return conflicts, nil
+}
+
+// pending reports whether the note has changes tasks does not have yet
+func (n *vaultNote) pending(tasks domain.TaskTree) bool {
+	for _, change := range n.changes {
+		if task := tasks.FindTask(change.TaskID); task != nil && task.State != change.State {
+			return true
+		}
+	}
+	return false
+}
+
+// scan reads the notes of every day with tasks or a section written before
+func (v *Vault) scan(tasks domain.TaskTree) ([]*vaultNote, *vaultSyncState, error) {
+	if info, err := os.Stat(v.Dir); err != nil {
+		return nil, nil, err
+	} else if !info.IsDir() {
+		return nil, nil, fmt.Errorf("%s is not a folder", v.Dir)
+	}
+	state, err := v.loadState()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	days := make(map[string]bool)
+	for _, date := range tasks.Dates() {
+		days[date] = true
+	}
+	for date := range state.Notes {
+		days[date] = true
+	}
+	dates := make([]string, 0, len(days))
+	for date := range days {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	notes := make([]*vaultNote, 0, len(dates))
+	for _, date := range dates {
+		note, err := v.readNote(tasks, date)
+		if err != nil {
+			return nil, nil, err
+		}
+		base, ok := state.Notes[date]
+		note.compare(tasks, base, ok)
+		notes = append(notes, note)
+	}
+	return notes, state, nil
+}
+
+// loadState reads the state file. A missing file, or one written for
+// another vault, has no sections.
+func (v *Vault) loadState() (*vaultSyncState, error) {
+	state := &vaultSyncState{Dir: v.Dir, Notes: make(map[string]string)}
+	data, err := os.ReadFile(v.statePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored vaultSyncState
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("%s: %w", v.statePath, err)
+	}
+	if stored.Dir == v.Dir && stored.Notes != nil {
+		state.Notes = stored.Notes
+	}
+	return state, nil
+}
+
+// readNote reads the daily note of date and finds its section
+func (v *Vault) readNote(tasks domain.TaskTree, date string) (*vaultNote, error) {
+	note := &vaultNote{
+		date:  date,
+		path:  filepath.Join(v.Dir, date+".md"),
+		begin: -1,
+		end:   -1,
+		want:  vaultSection(tasks.GetTasksForDate(date)),
+	}
+	data, err := os.ReadFile(note.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return note, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	note.content = string(data)
+	note.exists = true
+
+	start := strings.Index(note.content, vaultBegin+"\n")
+	if start < 0 {
+		start = strings.Index(note.content, vaultBegin+"\r\n")
+	}
+	if start < 0 {
+		return note, nil
+	}
+	note.begin = start + strings.Index(note.content[start:], "\n") + 1
+	end := strings.Index(note.content[note.begin:], vaultEnd)
+	if end < 0 {
+		note.conflicts = append(note.conflicts, VaultConflict{Date: date, Reason: "the section has no end marker"})
+		return note, nil
+	}
+	note.end = note.begin + end
+	note.body = note.content[note.begin:note.end]
+	return note, nil
+}
+
+// compare works out what changed in the note's section since base was
+// written. Without a base, a section that differs from the tasks cannot be
+// told apart from an edit, so it is a conflict.
+func (n *vaultNote) compare(tasks domain.TaskTree, base string, hasBase bool) {
+	body := strings.ReplaceAll(n.body, "\r\n", "\n")
+	if n.begin < 0 || len(n.conflicts) > 0 || body == base || (!hasBase && body == n.want) {
+		return
+	}
+	if !hasBase {
+		n.conflict("", "the section was not written by this data file; delete it to have it rewritten")
+		return
+	}
+
+	// Put back the checkboxes as written: any other difference is an edit
+	// seyal would lose
+	baseBoxes := make(map[string]string)
+	for _, line := range strings.Split(base, "\n") {
+		if m := vaultItem.FindStringSubmatch(line); m != nil {
+			baseBoxes[m[4]] = m[2]
+		}
+	}
+	lines := strings.Split(body, "\n")
+	var edited [][]string
+	for i, line := range lines {
+		m := vaultItem.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		box, ok := baseBoxes[m[4]]
+		if !ok || box == m[2] {
+			continue
+		}
+		lines[i] = m[1] + "- [" + box + "] " + m[3] + " <!-- seyal:" + m[4] + " -->"
+		edited = append(edited, m)
+	}
+	if strings.Join(lines, "\n") != base {
+		n.conflict("", "the section was edited beyond its checkboxes; undo the edit or delete the section")
+		return
+	}
+
+	for _, m := range edited {
+		title, _ := markdownTitle(m[3])
+		title, _ = domain.ParseTags(title)
+		id := m[4]
+		state, ok := vaultBoxState(m[2])
+		if !ok {
+			n.conflict(title, fmt.Sprintf("unknown checkbox [%s]", m[2]))
+			continue
+		}
+		baseState, _ := vaultBoxState(baseBoxes[id])
+		task := tasks.FindTask(id)
+		switch {
+		case task == nil:
+			n.conflict(title, "the task was deleted in seyal")
+		case task.State == state:
+			// Changed the same way on both sides
+		case task.State != baseState:
+			n.conflict(title, fmt.Sprintf("%s in the note but %s in seyal", state, task.State))
+		default:
+			n.changes = append(n.changes, VaultChange{Date: n.date, TaskID: id, Title: title, State: state})
+		}
+	}
+}
+
+func (n *vaultNote) conflict(title, reason string) {
+	n.conflicts = append(n.conflicts, VaultConflict{Date: n.date, Title: title, Reason: reason})
+}
+
+// vaultSection writes the section body for a day's tasks, a nested
+// checklist like the Markdown export's with each task's ID in a comment
+func vaultSection(tasks []*domain.Task) string {
+	var b strings.Builder
+	for _, ft := range domain.FlattenTasks(tasks, 0, false) {
+		task := ft.Task
+		title := strings.Join(strings.Fields(task.Title), " ")
+		b.WriteString(strings.Repeat("  ", ft.Depth) + "- [" + vaultBoxes[task.State] + "] " + title)
+		if len(task.Tags) > 0 {
+			b.WriteString(" " + task.TagText())
+		}
+		if p := markdownPriorityLabel(task.Priority); p != "" {
+			b.WriteString(" " + p)
+		}
+		b.WriteString(" <!-- seyal:" + task.ID + " -->\n")
+	}
+	return b.String()
+}